@@ -1,18 +1,33 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	_ "net/http/pprof"
 	"os"
-
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/Financial-Times/base-ft-rw-app-go/baseftrwapp"
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/http-handlers-go/httphandlers"
 	"github.com/Financial-Times/neo-utils-go/neoutils"
 	"github.com/Financial-Times/people-rw-neo4j/people"
+	"github.com/Financial-Times/service-status-go/gtg"
+	status "github.com/Financial-Times/service-status-go/httphandlers"
 	log "github.com/Sirupsen/logrus"
+	"github.com/cyberdelia/go-metrics-graphite"
+	"github.com/gorilla/mux"
 	"github.com/jawher/mow.cli"
+	"github.com/rcrowley/go-metrics"
 )
 
 func main() {
@@ -53,33 +68,426 @@ func main() {
 		Desc:   "Whether to log metrics. Set to true if running locally and you want metrics output",
 		EnvVar: "LOG_METRICS",
 	})
+	prometheusMetrics := app.Bool(cli.BoolOpt{
+		Name:   "prometheusMetrics",
+		Value:  false,
+		Desc:   "Whether to serve metrics for Prometheus on /metrics, as well as sending them to Graphite",
+		EnvVar: "PROMETHEUS_METRICS",
+	})
+	otlpEndpoint := app.String(cli.StringOpt{
+		Name:   "otlpEndpoint",
+		Value:  "",
+		Desc:   "OpenTelemetry collector OTLP/HTTP endpoint to send traces to, e.g. http://localhost:4318. Leave as default to turn tracing off",
+		EnvVar: "OTEL_EXPORTER_OTLP_ENDPOINT",
+	})
+	startupDeadline := app.String(cli.StringOpt{
+		Name:   "startupDeadline",
+		Value:  "5m",
+		Desc:   "How long to keep retrying to connect to neo4j and create the indexes and constraints before exiting",
+		EnvVar: "STARTUP_DEADLINE",
+	})
+	shutdownTimeout := app.String(cli.StringOpt{
+		Name:   "shutdownTimeout",
+		Value:  "30s",
+		Desc:   "How long to wait on SIGTERM for requests in flight and background jobs to finish before exiting anyway",
+		EnvVar: "SHUTDOWN_TIMEOUT",
+	})
+	shutdownDrain := app.String(cli.StringOpt{
+		Name:   "shutdownDrain",
+		Value:  "5s",
+		Desc:   "How long to keep serving on SIGTERM after __gtg starts failing, so load balancers stop sending requests before the service stops accepting them",
+		EnvVar: "SHUTDOWN_DRAIN",
+	})
+	readTimeout := app.String(cli.StringOpt{
+		Name:   "readTimeout",
+		Value:  people.DefaultTimeouts().Read.String(),
+		Desc:   "How long a read waits for neo4j before giving up. 0 turns this off",
+		EnvVar: "READ_TIMEOUT",
+	})
+	writeTimeout := app.String(cli.StringOpt{
+		Name:   "writeTimeout",
+		Value:  people.DefaultTimeouts().Write.String(),
+		Desc:   "How long a write waits for neo4j before giving up. 0 turns this off",
+		EnvVar: "WRITE_TIMEOUT",
+	})
+	deleteTimeout := app.String(cli.StringOpt{
+		Name:   "deleteTimeout",
+		Value:  people.DefaultTimeouts().Delete.String(),
+		Desc:   "How long a delete waits for neo4j before giving up. 0 turns this off",
+		EnvVar: "DELETE_TIMEOUT",
+	})
+	idsTimeout := app.String(cli.StringOpt{
+		Name:   "idsTimeout",
+		Value:  people.DefaultTimeouts().IDs.String(),
+		Desc:   "How long each page of __ids waits for neo4j before giving up. 0 turns this off",
+		EnvVar: "IDS_TIMEOUT",
+	})
+	countTimeout := app.String(cli.StringOpt{
+		Name:   "countTimeout",
+		Value:  people.DefaultTimeouts().Count.String(),
+		Desc:   "How long __count waits for neo4j before giving up. 0 turns this off",
+		EnvVar: "COUNT_TIMEOUT",
+	})
+	writeProbeInterval := app.String(cli.StringOpt{
+		Name:   "writeProbeInterval",
+		Value:  "1m",
+		Desc:   "How often the healthcheck times a probe write to neo4j, giving the last result in between. 0 turns the probe off",
+		EnvVar: "WRITE_PROBE_INTERVAL",
+	})
+	schemaCheckInterval := app.String(cli.StringOpt{
+		Name:   "schemaCheckInterval",
+		Value:  "1m",
+		Desc:   "How often __gtg and the healthcheck look for the neo4j indexes and constraints, giving the last result in between. 0 looks on every poll",
+		EnvVar: "SCHEMA_CHECK_INTERVAL",
+	})
+	writeAttempts := app.Int(cli.IntOpt{
+		Name:   "writeAttempts",
+		Value:  people.DefaultRetryPolicy().Attempts,
+		Desc:   "How many times to try a write or delete which fails with a transient neo4j error, such as a deadlock. 1 turns retrying off",
+		EnvVar: "WRITE_ATTEMPTS",
+	})
+	breakerErrorRate := app.String(cli.StringOpt{
+		Name:   "circuitBreakerErrorRate",
+		Value:  strconv.FormatFloat(people.DefaultCircuitBreakerSettings().ErrorRate, 'f', -1, 64),
+		Desc:   "Fraction of neo4j batches failing, e.g. 0.5, at which to stop sending requests to neo4j for a while. 0 turns this off",
+		EnvVar: "CIRCUIT_BREAKER_ERROR_RATE",
+	})
+	breakerMinRequests := app.Int(cli.IntOpt{
+		Name:   "circuitBreakerMinRequests",
+		Value:  people.DefaultCircuitBreakerSettings().MinRequests,
+		Desc:   "Fewest neo4j batches in circuitBreakerWindow for their error rate to count",
+		EnvVar: "CIRCUIT_BREAKER_MIN_REQUESTS",
+	})
+	breakerWindow := app.String(cli.StringOpt{
+		Name:   "circuitBreakerWindow",
+		Value:  people.DefaultCircuitBreakerSettings().Window.String(),
+		Desc:   "Period over which the neo4j error rate is measured",
+		EnvVar: "CIRCUIT_BREAKER_WINDOW",
+	})
+	breakerOpenFor := app.String(cli.StringOpt{
+		Name:   "circuitBreakerOpenFor",
+		Value:  people.DefaultCircuitBreakerSettings().OpenFor.String(),
+		Desc:   "How long to stop sending requests to neo4j for before trying again",
+		EnvVar: "CIRCUIT_BREAKER_OPEN_FOR",
+	})
+	maxConcurrentWrites := app.Int(cli.IntOpt{
+		Name:   "maxConcurrentWrites",
+		Value:  people.DefaultWriteLimits().MaxConcurrent,
+		Desc:   "Most writes and deletes to run at once. 0 turns this off",
+		EnvVar: "MAX_CONCURRENT_WRITES",
+	})
+	maxConcurrentWritesPerClient := app.Int(cli.IntOpt{
+		Name:   "maxConcurrentWritesPerClient",
+		Value:  people.DefaultWriteLimits().MaxConcurrentPerClient,
+		Desc:   "Most writes and deletes to run at once for a single client. 0 turns this off",
+		EnvVar: "MAX_CONCURRENT_WRITES_PER_CLIENT",
+	})
+	writesPerSecond := app.String(cli.StringOpt{
+		Name:   "writesPerSecond",
+		Value:  "0",
+		Desc:   "Most writes and deletes to allow a second. 0 turns this off",
+		EnvVar: "WRITES_PER_SECOND",
+	})
+	writesPerSecondPerClient := app.String(cli.StringOpt{
+		Name:   "writesPerSecondPerClient",
+		Value:  "0",
+		Desc:   "Most writes and deletes to allow a second for a single client. 0 turns this off",
+		EnvVar: "WRITES_PER_SECOND_PER_CLIENT",
+	})
+	writeQueueTimeout := app.String(cli.StringOpt{
+		Name:   "writeQueueTimeout",
+		Value:  people.DefaultWriteLimits().MaxWait.String(),
+		Desc:   "How long a write or delete waits for the limits to allow it before being refused with 429",
+		EnvVar: "WRITE_QUEUE_TIMEOUT",
+	})
+	writeClientHeader := app.String(cli.StringOpt{
+		Name:   "writeClientHeader",
+		Value:  people.DefaultWriteLimits().ClientHeader,
+		Desc:   "Request header telling clients apart for the per client write limits",
+		EnvVar: "WRITE_CLIENT_HEADER",
+	})
+	writeLimitClients := app.Strings(cli.StringsOpt{
+		Name:   "writeLimitClients",
+		Value:  []string{},
+		Desc:   "The only client ids given per client write limits of their own, others sharing them. Empty for any client",
+		EnvVar: "WRITE_LIMIT_CLIENTS",
+	})
+	logLevel := app.String(cli.StringOpt{
+		Name:   "logLevel",
+		Value:  "info",
+		Desc:   "Level to log at. debug logs every Cypher batch and write decision against its transaction id",
+		EnvVar: "LOG_LEVEL",
+	})
 	env := app.String(cli.StringOpt{
 		Name:  "env",
 		Value: "local",
 		Desc:  "environment this app is running in",
 	})
+	authoritiesFile := app.String(cli.StringOpt{
+		Name:   "identifierAuthorities",
+		Value:  "",
+		Desc:   "JSON file listing identifier authorities in addition to the built in ones, as [{\"uri\":\"...\",\"label\":\"...Identifier\"}]",
+		EnvVar: "IDENTIFIER_AUTHORITIES_FILE",
+	})
+	identifierSweepInterval := app.String(cli.StringOpt{
+		Name:   "identifierSweepInterval",
+		Value:  "1h",
+		Desc:   "How often to remove Identifier nodes which no longer identify anything, e.g. 30m. 0 turns this off",
+		EnvVar: "IDENTIFIER_SWEEP_INTERVAL",
+	})
+	identifierSweepBatchSize := app.Int(cli.IntOpt{
+		Name:   "identifierSweepBatchSize",
+		Value:  people.DefaultRepairBatchSize,
+		Desc:   "Maximum number of orphaned Identifier nodes to remove per transaction",
+		EnvVar: "IDENTIFIER_SWEEP_BATCH_SIZE",
+	})
+
+	app.Command("export", "Export every person to a gzipped newline delimited JSON file", func(cmd *cli.Cmd) {
+		cmd.Spec = "FILE"
+		file := cmd.String(cli.StringArg{
+			Name: "FILE",
+			Desc: "File to write the export to",
+		})
+
+		cmd.Action = func() {
+			peopleDriver, err := people.NewCypherPeopleServiceWithAuthorities(connectOrExit(*neoURL, *batchSize), loadAuthoritiesOrExit(*authoritiesFile))
+			if err != nil {
+				log.Fatalf("Invalid identifier authorities, error=[%s]", err)
+			}
+			// a command should not be stopped part way through by failures the breaker is there to shed load for
+			peopleDriver = peopleDriver.WithCircuitBreaker(people.CircuitBreakerSettings{})
+
+			f, err := os.Create(*file)
+			if err != nil {
+				log.Fatalf("Could not create export file, error=[%s]", err)
+			}
+			defer f.Close()
+
+			count, err := peopleDriver.Export(f)
+			if err != nil {
+				log.Fatalf("Export failed after %d people, error=[%s]", count, err)
+			}
+			log.Infof("Exported %d people to %s", count, *file)
+		}
+	})
+
+	app.Command("import", "Import people from a gzipped newline delimited JSON file created by export", func(cmd *cli.Cmd) {
+		cmd.Spec = "FILE"
+		file := cmd.String(cli.StringArg{
+			Name: "FILE",
+			Desc: "File to read the import from",
+		})
+
+		cmd.Action = func() {
+			peopleDriver, err := people.NewCypherPeopleServiceWithAuthorities(connectOrExit(*neoURL, *batchSize), loadAuthoritiesOrExit(*authoritiesFile))
+			if err != nil {
+				log.Fatalf("Invalid identifier authorities, error=[%s]", err)
+			}
+			// a command should not be stopped part way through by failures the breaker is there to shed load for
+			peopleDriver = peopleDriver.WithCircuitBreaker(people.CircuitBreakerSettings{})
+			if err := peopleDriver.Initialise(); err != nil {
+				log.Fatalf("Could not initialise constraints, error=[%s]", err)
+			}
+
+			f, err := os.Open(*file)
+			if err != nil {
+				log.Fatalf("Could not open import file, error=[%s]", err)
+			}
+			defer f.Close()
+
+			count, err := peopleDriver.Import(f)
+			if err != nil {
+				log.Fatalf("Import failed after %d people, error=[%s]", count, err)
+			}
+			log.Infof("Imported %d people from %s", count, *file)
+		}
+	})
+
+	app.Command("duplicates", "Report people which are probably the same person as JSON on stdout", func(cmd *cli.Cmd) {
+		minConfidence := cmd.String(cli.StringOpt{
+			Name:  "minConfidence",
+			Value: strconv.FormatFloat(people.DefaultDuplicateConfidence, 'f', -1, 64),
+			Desc:  "Only report pairs with at least this confidence, between 0 and 1",
+		})
+
+		cmd.Action = func() {
+			confidence, err := strconv.ParseFloat(*minConfidence, 64)
+			if err != nil {
+				log.Fatalf("Invalid minConfidence, error=[%s]", err)
+			}
+			if confidence < 0 || confidence > 1 {
+				log.Fatalf("Invalid minConfidence %s, must be between 0 and 1", *minConfidence)
+			}
+
+			peopleDriver, err := people.NewCypherPeopleServiceWithAuthorities(connectOrExit(*neoURL, *batchSize), loadAuthoritiesOrExit(*authoritiesFile))
+			if err != nil {
+				log.Fatalf("Invalid identifier authorities, error=[%s]", err)
+			}
+			// a command should not be stopped part way through by failures the breaker is there to shed load for
+			peopleDriver = peopleDriver.WithCircuitBreaker(people.CircuitBreakerSettings{})
+
+			duplicates, err := peopleDriver.Duplicates(confidence)
+			if err != nil {
+				log.Fatalf("Could not find duplicates, error=[%s]", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(duplicates); err != nil {
+				log.Fatalf("Could not write duplicates, error=[%s]", err)
+			}
+			log.Infof("Found %d probable duplicates", len(duplicates))
+		}
+	})
+
+	app.Command("verify", "Check the graph for integrity problems, reporting them as JSON on stdout", func(cmd *cli.Cmd) {
+		repair := cmd.Bool(cli.BoolOpt{
+			Name:  "repair",
+			Value: false,
+			Desc:  "Fix the problems found",
+		})
+		repairBatchSize := cmd.Int(cli.IntOpt{
+			Name:  "repairBatchSize",
+			Value: people.DefaultRepairBatchSize,
+			Desc:  "Number of problems to fix per transaction",
+		})
+
+		cmd.Action = func() {
+			peopleDriver, err := people.NewCypherPeopleServiceWithAuthorities(connectOrExit(*neoURL, *batchSize), loadAuthoritiesOrExit(*authoritiesFile))
+			if err != nil {
+				log.Fatalf("Invalid identifier authorities, error=[%s]", err)
+			}
+			// a command should not be stopped part way through by failures the breaker is there to shed load for
+			peopleDriver = peopleDriver.WithCircuitBreaker(people.CircuitBreakerSettings{})
+
+			problems, err := peopleDriver.Verify(*repair, *repairBatchSize)
+			if err != nil {
+				log.Fatalf("Verification failed, error=[%s]", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(problems); err != nil {
+				log.Fatalf("Could not write report, error=[%s]", err)
+			}
+
+			// so scheduled runs fail while there is something to look at
+			unrepaired := 0
+			for _, problem := range problems {
+				unrepaired += problem.Count - problem.Repaired
+			}
+			if unrepaired > 0 {
+				log.Errorf("%d integrity problems left unrepaired", unrepaired)
+				os.Exit(1)
+			}
+		}
+	})
 
 	app.Action = func() {
+		timeouts := people.Timeouts{
+			Read:   durationOrExit("readTimeout", *readTimeout),
+			Write:  durationOrExit("writeTimeout", *writeTimeout),
+			Delete: durationOrExit("deleteTimeout", *deleteTimeout),
+			IDs:    durationOrExit("idsTimeout", *idsTimeout),
+			Count:  durationOrExit("countTimeout", *countTimeout),
+		}
+
 		conf := neoutils.DefaultConnectionConfig()
 		conf.BatchSize = *batchSize
-		db, err := neoutils.Connect(*neoURL, conf)
+		// a batch which has timed out is left running in the background, until neo4j answers or this gives up on it
+		conf.HTTPClient.Timeout = timeouts.ClientTimeout(conf.HTTPClient.Timeout)
+		db, err := people.Connect(*neoURL, conf)
 
+		// the connection is made in the background, so this only fails for a bad configuration
 		if err != nil {
-			log.Errorf("Could not connect to neo4j, error=[%s]\n", err)
+			log.Fatalf("Could not connect to neo4j, error=[%s]", err)
 		}
 
-		peopleDriver := people.NewCypherPeopleService(db)
-		peopleDriver.Initialise()
+		peopleDriver, err := people.NewCypherPeopleServiceWithAuthorities(db, loadAuthoritiesOrExit(*authoritiesFile))
+		if err != nil {
+			log.Fatalf("Invalid identifier authorities, error=[%s]", err)
+		}
+		peopleDriver = peopleDriver.WithTimeouts(timeouts)
+		retries := people.DefaultRetryPolicy()
+		retries.Attempts = *writeAttempts
+		peopleDriver = peopleDriver.WithRetryPolicy(retries)
+
+		errorRate, err := strconv.ParseFloat(*breakerErrorRate, 64)
+		if err != nil || errorRate < 0 || errorRate > 1 {
+			log.Fatalf("Invalid circuitBreakerErrorRate %s, must be a number between 0 and 1", *breakerErrorRate)
+		}
+		peopleDriver = peopleDriver.WithCircuitBreaker(people.CircuitBreakerSettings{
+			ErrorRate:   errorRate,
+			MinRequests: *breakerMinRequests,
+			Window:      durationOrExit("circuitBreakerWindow", *breakerWindow),
+			OpenFor:     durationOrExit("circuitBreakerOpenFor", *breakerOpenFor),
+		})
+
+		deadline, err := time.ParseDuration(*startupDeadline)
+		if err != nil {
+			log.Fatalf("Invalid startupDeadline, error=[%s]", err)
+		}
 
 		baseftrwapp.OutputMetricsIfRequired(*graphiteTCPAddress, *graphitePrefix, *logMetrics)
+		if *otlpEndpoint != "" {
+			if err := people.EnableOTLPTracing(*otlpEndpoint, "people-rw-neo4j"); err != nil {
+				log.Fatalf("Invalid otlpEndpoint %s, error=[%s]", *otlpEndpoint, err)
+			}
+		}
 
-		services := map[string]baseftrwapp.Service{
-			"people": peopleDriver,
+		sweepInterval, err := time.ParseDuration(*identifierSweepInterval)
+		if err != nil {
+			log.Fatalf("Invalid identifierSweepInterval, error=[%s]", err)
 		}
 
-		var checks []fthealth.Check
-		for _, service := range services {
-			checks = append(checks, makeCheck(service, db))
+		drainTimeout, err := time.ParseDuration(*shutdownTimeout)
+		if err != nil {
+			log.Fatalf("Invalid shutdownTimeout, error=[%s]", err)
+		}
+		drain := durationOrExit("shutdownDrain", *shutdownDrain)
+
+		// background jobs finish when stopping is closed, so that a sweep is not cut off part way through a batch
+		var background sync.WaitGroup
+		stopping := make(chan struct{})
+
+		// the server starts straight away so health checks can be answered, but is not good to go until the schema is
+		// confirmed. If that never happens the instance exits so it can be replaced.
+		var schemaReady int32
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			err := initialiseWithin(peopleDriver.Initialise, deadline, stopping)
+			if err == errStopped {
+				log.Info("Stopped initialising neo4j schema to shut down")
+				return
+			}
+			if err != nil {
+				log.Fatalf("Could not initialise neo4j schema, error=[%s]", err)
+			}
+			atomic.StoreInt32(&schemaReady, 1)
+			log.Info("Neo4j schema initialised")
+
+			if sweepInterval > 0 && !closed(stopping) {
+				sweeper := people.NewIdentifierSweeper(peopleDriver, sweepInterval, *identifierSweepBatchSize)
+				go func() {
+					<-stopping
+					sweeper.Stop()
+				}()
+				sweeper.Run()
+			}
+		}()
+
+		// polled by load balancers every few seconds, so the result is kept rather than listing the schema every time.
+		// Nothing is kept until the schema has been initialised, so a result from before then is not reported after.
+		schemaCheck := peopleDriver.CheckSchema
+		if interval := durationOrExit("schemaCheckInterval", *schemaCheckInterval); interval > 0 {
+			schemaCheck = rateLimitedCheck(interval, schemaCheck)
+		}
+		checkSchema := func() (string, error) {
+			if atomic.LoadInt32(&schemaReady) == 0 {
+				return "", errors.New("neo4j schema is not initialised yet")
+			}
+			return schemaCheck()
 		}
 
 		timedHC := fthealth.TimedHealthCheck{
@@ -87,19 +495,104 @@ func main() {
 				SystemCode:  "people-rw-neo4j",
 				Description: "Writes 'people' to Neo4j, usually as part of a bulk upload done on a schedule",
 				Name:        "people-rw-neo4j",
-				Checks:      checks,
+				Checks:      makeChecks(peopleDriver, checkSchema, *neoURL, durationOrExit("writeProbeInterval", *writeProbeInterval)),
 			},
 			Timeout: 10 * time.Second,
 		}
 
-		baseftrwapp.RunServerWithConf(baseftrwapp.RWConf{
-			Services:      services,
-			HealthHandler: fthealth.Handler(timedHC),
-			Port:          *port,
-			ServiceName:   "people-rw-neo4j",
-			Env:           *env,
-			EnableReqLog:  true,
-		})
+		// without Neo4j, or without the constraints keeping people unique, this instance should not take traffic
+		var shuttingDown int32
+		gtgChecker := func() gtg.Status {
+			if atomic.LoadInt32(&shuttingDown) == 1 {
+				return gtg.Status{GoodToGo: false, Message: "Shutting down"}
+			}
+			if atomic.LoadInt32(&schemaReady) == 0 {
+				return gtg.Status{GoodToGo: false, Message: "Neo4j schema is not initialised yet"}
+			}
+			if err := peopleDriver.Check(); err != nil {
+				return gtg.Status{GoodToGo: false, Message: err.Error()}
+			}
+			if _, err := checkSchema(); err != nil {
+				return gtg.Status{GoodToGo: false, Message: err.Error()}
+			}
+			return gtg.Status{GoodToGo: true}
+		}
+
+		router := mux.NewRouter()
+		people.NewPeopleHandler(peopleDriver).WithWriteLimits(people.WriteLimits{
+			MaxConcurrent:          *maxConcurrentWrites,
+			MaxConcurrentPerClient: *maxConcurrentWritesPerClient,
+			PerSecond:              rateOrExit("writesPerSecond", *writesPerSecond),
+			PerSecondPerClient:     rateOrExit("writesPerSecondPerClient", *writesPerSecondPerClient),
+			MaxWait:                durationOrExit("writeQueueTimeout", *writeQueueTimeout),
+			ClientHeader:           *writeClientHeader,
+			Clients:                *writeLimitClients,
+		}).RegisterHandlers(router)
+		router.HandleFunc("/__health", fthealth.Handler(timedHC))
+
+		if *prometheusMetrics {
+			http.HandleFunc("/metrics", people.MetricsHandler)
+		}
+
+		if *env != "local" {
+			f, err := os.OpenFile("/var/log/apps/people-rw-neo4j-go-app.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
+			if err != nil {
+				log.Fatalf("Failed to initialise log file, %v", err)
+			}
+			defer f.Close()
+			log.SetOutput(f)
+			log.SetFormatter(&log.TextFormatter{DisableColors: true})
+		}
+
+		srv := newServer(router, gtgChecker, *port)
+		go func() {
+			log.Infof("Listening on port %d in environment %s", *port, *env)
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("Unable to start server: %v", err)
+			}
+		}()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		sig := <-signals
+
+		log.Infof("Received %s, draining for %s then shutting down within %s", sig, drain, drainTimeout)
+		atomic.StoreInt32(&shuttingDown, 1)
+		close(stopping)
+		// requests keep being served until load balancers have seen __gtg failing and stopped sending them
+		select {
+		case <-time.After(drain):
+		case sig = <-signals:
+			log.Infof("Received %s, shutting down without draining", sig)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Warnf("Requests were still in flight when the shutdown timeout passed, error=[%s]", err)
+		}
+		if err := waitWithin(ctx, &background); err != nil {
+			log.Warnf("Background jobs were still running when the shutdown timeout passed, error=[%s]", err)
+		}
+
+		flushMetrics(*graphiteTCPAddress, *graphitePrefix)
+		people.FlushTracing(5 * time.Second)
+		// neo4j is spoken to over HTTP, so closing the connections is closing those kept alive by the client
+		if conf.HTTPClient != nil {
+			if t, ok := conf.HTTPClient.Transport.(*http.Transport); ok {
+				t.CloseIdleConnections()
+			}
+		}
+		log.Info("Shut down")
+	}
+
+	app.Before = func() {
+		level, err := log.ParseLevel(*logLevel)
+		if err != nil {
+			log.Fatalf("Invalid logLevel, error=[%s]", err)
+		}
+		log.SetLevel(level)
 	}
 
 	log.SetLevel(log.InfoLevel)
@@ -107,13 +600,228 @@ func main() {
 	app.Run(os.Args)
 }
 
-func makeCheck(service baseftrwapp.Service, cr neoutils.CypherRunner) fthealth.Check {
-	return fthealth.Check{
-		BusinessImpact:   "Cannot read/write people via this writer",
-		Name:             "Check connectivity to Neo4j - neoUrl is a parameter in hieradata for this service",
-		PanicGuide:       "TODO - write panic guide",
-		Severity:         1,
-		TechnicalSummary: fmt.Sprintf("Cannot connect to Neo4j instance %s with at least one person loaded in it", cr),
-		Checker:          func() (string, error) { return "", service.Check() },
+// newServer serves the router with request logging and metrics. The status endpoints are registered separately so
+// that they are not logged, as they are polled constantly.
+func newServer(router *mux.Router, gtgChecker gtg.StatusChecker, port int) *http.Server {
+	http.HandleFunc(status.PingPath, status.PingHandler)
+	http.HandleFunc(status.PingPathDW, status.PingHandler)
+	http.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
+	http.HandleFunc(status.BuildInfoPathDW, status.BuildInfoHandler)
+	http.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(gtgChecker))
+
+	var h http.Handler = httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), router)
+	h = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, h)
+	http.Handle("/", h)
+
+	return &http.Server{Addr: fmt.Sprintf(":%d", port)}
+}
+
+// waitWithin waits for the group to finish, or for the context to be done if that is sooner
+func waitWithin(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closed reports whether the channel has been closed, without waiting
+func closed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// flushMetrics sends the metrics collected since graphite was last written to, which would otherwise be lost on exit
+func flushMetrics(graphiteTCPAddress string, graphitePrefix string) {
+	if graphiteTCPAddress == "" {
+		return
+	}
+	addr, err := net.ResolveTCPAddr("tcp", graphiteTCPAddress)
+	if err != nil {
+		log.Warnf("Could not flush metrics to graphite, error=[%s]", err)
+		return
+	}
+	err = graphite.Once(graphite.Config{
+		Addr:          addr,
+		Registry:      metrics.DefaultRegistry,
+		FlushInterval: time.Minute,
+		DurationUnit:  time.Nanosecond,
+		Prefix:        graphitePrefix,
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+	})
+	if err != nil {
+		log.Warnf("Could not flush metrics to graphite, error=[%s]", err)
+	}
+}
+
+const maxInitialiseBackoff = 30 * time.Second
+
+// errStopped is returned by initialiseWithin when the service is shutting down before the schema is initialised
+var errStopped = errors.New("stopped while initialising")
+
+// initialiseWithin retries initialise, which fails until neo4j can be reached, backing off exponentially until the
+// deadline has passed or stopping is closed
+func initialiseWithin(initialise func() error, deadline time.Duration, stopping <-chan struct{}) error {
+	giveUp := time.Now().Add(deadline)
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		err := initialise()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(wait).After(giveUp) {
+			return fmt.Errorf("gave up after %d attempts in %s: %v", attempt, deadline, err)
+		}
+		log.Warnf("Could not initialise neo4j schema on attempt %d, retrying in %s, error=[%s]", attempt, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-stopping:
+			return errStopped
+		}
+
+		wait *= 2
+		if wait > maxInitialiseBackoff {
+			wait = maxInitialiseBackoff
+		}
+	}
+}
+
+// durationOrExit parses the duration given for the option, as there is no duration option type
+func durationOrExit(option string, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s, error=[%s]", option, err)
+	}
+	return d
+}
+
+// rateOrExit parses the number of operations a second given for the option
+func rateOrExit(option string, value string) float64 {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		log.Fatalf("Invalid %s %s, must be a number of operations a second", option, value)
+	}
+	return rate
+}
+
+// connectOrExit connects to neo4j in the foreground, as the command line tools need a working connection before doing anything
+func connectOrExit(neoURL string, batchSize int) neoutils.NeoConnection {
+	conf := neoutils.DefaultConnectionConfig()
+	conf.BatchSize = batchSize
+	conf.BackgroundConnect = false
+	db, err := people.Connect(neoURL, conf)
+	if err != nil {
+		log.Fatalf("Could not connect to neo4j, error=[%s]", err)
+	}
+	return db
+}
+
+func loadAuthoritiesOrExit(path string) []people.Authority {
+	authorities, err := people.LoadAuthorities(path)
+	if err != nil {
+		log.Fatalf("Could not load identifier authorities, error=[%s]", err)
+	}
+	return authorities
+}
+
+// writeLatencyThreshold is how long a single write can take before the health check warns of a slow Neo4j
+const writeLatencyThreshold = 2 * time.Second
+
+// rateLimitedCheck runs checker at most once every interval, giving its last result in between, so polling the
+// healthcheck does not load Neo4j. An interval of 0 turns the check off.
+func rateLimitedCheck(interval time.Duration, checker func() (string, error)) func() (string, error) {
+	if interval <= 0 {
+		return func() (string, error) { return "Turned off", nil }
+	}
+
+	var lock sync.Mutex
+	var lastRun time.Time
+	var lastMessage string
+	var lastErr error
+	return func() (string, error) {
+		// held while the check runs, so concurrent polls wait for it rather than running their own
+		lock.Lock()
+		defer lock.Unlock()
+		if lastRun.IsZero() || time.Since(lastRun) >= interval {
+			lastMessage, lastErr = checker()
+			lastRun = time.Now()
+		}
+		return lastMessage, lastErr
+	}
+}
+
+type peopleChecker interface {
+	Check() error
+	CheckPeopleLoaded() (string, error)
+	CheckWriteLatency(threshold time.Duration) (string, error)
+	CheckCircuitBreaker() (string, error)
+}
+
+// makeChecks returns the healthcheck's checks. checkSchema is shared with __gtg, so that the two report the same.
+func makeChecks(peopleDriver peopleChecker, checkSchema func() (string, error), neoURL string, writeProbeInterval time.Duration) []fthealth.Check {
+	return []fthealth.Check{
+		{
+			ID:             "neo4j-connectivity",
+			BusinessImpact: "People cannot be read or written, so new and updated people will not reach content, annotations or the site",
+			Name:           "Neo4j connectivity",
+			PanicGuide: "Check Neo4j at " + neoURL + " is up and reachable from this service: the neo-url parameter may be wrong, " +
+				"or the Neo4j cluster may be down or switching leader. Restarting this service will not help until Neo4j is reachable.",
+			Severity:         1,
+			TechnicalSummary: fmt.Sprintf("Cannot run a query against Neo4j at %s", neoURL),
+			Checker:          func() (string, error) { return "Connected to Neo4j", peopleDriver.Check() },
+		},
+		{
+			ID:             "neo4j-schema",
+			BusinessImpact: "Duplicate people or identifiers can be written, which shows as authors appearing twice and broken concordance",
+			Name:           "Neo4j indexes and constraints",
+			PanicGuide: "The indexes and uniqueness constraints are created when the service starts. Restart it, and if the check still fails " +
+				"look for constraint creation errors in its logs, usually caused by duplicate nodes which need removing first (see `verify`).",
+			Severity:         2,
+			TechnicalSummary: "An index or uniqueness constraint created by Initialise is missing from Neo4j, or an index is not online",
+			Checker:          checkSchema,
+		},
+		{
+			ID:             "people-loaded",
+			BusinessImpact: "Reads of every person return 404, so people pages and author information are missing",
+			Name:           "People loaded",
+			PanicGuide: "Neo4j has no people in it, most likely because it was rebuilt. Restore from an export with the `import` command " +
+				"or republish people from the transformer.",
+			Severity:         2,
+			TechnicalSummary: "There are no Person nodes in Neo4j",
+			Checker:          peopleDriver.CheckPeopleLoaded,
+		},
+		{
+			ID:             "neo4j-write-latency",
+			BusinessImpact: "Writes are slow, so bulk loads of people take longer and updates are delayed",
+			Name:           "Neo4j write latency",
+			PanicGuide: "Check the load on the Neo4j leader and whether a bulk load or long running query is in progress. " +
+				"Slow writes usually recover once the load drops.",
+			Severity:         3,
+			TechnicalSummary: fmt.Sprintf("A single write to Neo4j took more than %s", writeLatencyThreshold),
+			Checker: rateLimitedCheck(writeProbeInterval, func() (string, error) {
+				return peopleDriver.CheckWriteLatency(writeLatencyThreshold)
+			}),
+		},
+		{
+			ID:             "neo4j-circuit-breaker",
+			BusinessImpact: "Reads and writes of people are being refused without trying Neo4j, so updates are not reaching content or the site",
+			Name:           "Neo4j circuit breaker",
+			PanicGuide: "Too many requests to Neo4j failed or timed out, so the service has stopped sending them for a while. " +
+				"Check the neo4j-connectivity check and the Neo4j cluster; the breaker closes by itself once a request succeeds.",
+			Severity:         2,
+			TechnicalSummary: "The circuit breaker around Neo4j is open or half open, returning 503 with Retry-After",
+			Checker:          peopleDriver.CheckCircuitBreaker,
+		},
 	}
 }
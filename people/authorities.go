@@ -0,0 +1,218 @@
+package people
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+)
+
+const (
+	tmeAuthority                   = "http://api.ft.com/system/FT-TME"
+	uppAuthority                   = "http://api.ft.com/system/FT-UPP"
+	factsetAuthority               = "http://api.ft.com/system/FACTSET"
+	wikidataAuthority              = "http://www.wikidata.org/entity/"
+	orcidAuthority                 = "https://orcid.org/"
+	leiAuthority                   = "http://api.ft.com/system/LEI"
+	companiesHouseOfficerAuthority = "http://api.ft.com/system/COMPANIES-HOUSE-OFFICER"
+	// sameAs links are stored as identifiers too, so they are unique and can be looked up like any other
+	sameAsAuthority = "http://www.w3.org/2002/07/owl#sameAs"
+)
+
+const wikidataIdentifierLabel = "WikidataIdentifier"
+
+var wikidataQIDRegex = regexp.MustCompile(`^Q[1-9][0-9]*$`)
+
+// Authority is an issuer of identifiers for people, and the label its Identifier nodes are given in Neo4j.
+// Each label gets a uniqueness constraint on value. An authority reusing the URI or label of one listed before it is
+// rejected, unless Override is set, in which case it replaces that one.
+type Authority struct {
+	URI      string `json:"uri"`
+	Label    string `json:"label"`
+	Override bool   `json:"override,omitempty"`
+}
+
+// labels are written into Cypher statements, so are restricted to something that cannot be used for injection
+var authorityLabelRegex = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*Identifier$`)
+
+// DefaultAuthorities are the identifier authorities known without any configuration
+func DefaultAuthorities() []Authority {
+	return []Authority{
+		{URI: tmeAuthority, Label: tmeIdentifierLabel},
+		{URI: uppAuthority, Label: uppIdentifierLabel},
+		{URI: factsetAuthority, Label: factsetIdentifierLabel},
+		{URI: wikidataAuthority, Label: wikidataIdentifierLabel},
+		{URI: sameAsAuthority, Label: "SameAsIdentifier"},
+		{URI: orcidAuthority, Label: "ORCIDIdentifier"},
+		{URI: leiAuthority, Label: "LEIIdentifier"},
+		{URI: companiesHouseOfficerAuthority, Label: "CompaniesHouseOfficerIdentifier"},
+	}
+}
+
+// LoadAuthorities returns the default authorities plus those in the JSON file at path, which is a list of
+// {"uri":"...","label":"..."} objects. An empty path returns just the defaults.
+func LoadAuthorities(path string) ([]Authority, error) {
+	authorities := DefaultAuthorities()
+	if path == "" {
+		return authorities, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var configured []Authority
+	if err := json.NewDecoder(f).Decode(&configured); err != nil {
+		return nil, fmt.Errorf("could not read identifier authorities from %s: %v", path, err)
+	}
+
+	return append(authorities, configured...), nil
+}
+
+// byAuthority lists every identifier of a person, including those with fields of their own, against its authority
+func (ids alternativeIdentifiers) byAuthority() []identifier {
+	var all []identifier
+	for _, value := range ids.TME {
+		all = append(all, identifier{tmeAuthority, value})
+	}
+	for _, value := range ids.UUIDS {
+		all = append(all, identifier{uppAuthority, value})
+	}
+	for _, value := range ids.normalised().FactsetIdentifiers {
+		all = append(all, identifier{factsetAuthority, value})
+	}
+	if ids.WikidataIdentifier != "" {
+		all = append(all, identifier{wikidataAuthority, ids.WikidataIdentifier})
+	}
+	for _, uri := range ids.SameAs {
+		all = append(all, identifier{sameAsAuthority, uri})
+	}
+	return append(all, ids.Identifiers...)
+}
+
+// fieldsForAuthorities are the fields for identifiers which have one of their own. They are not accepted in the
+// identifiers list as well, where they would skip the field's normalisation and validation.
+var fieldsForAuthorities = map[string]string{
+	tmeAuthority:      "TME",
+	uppAuthority:      "uuids",
+	factsetAuthority:  "factsetIdentifiers",
+	wikidataAuthority: "wikidataIdentifier",
+	sameAsAuthority:   "sameAs",
+}
+
+func validateIdentifiers(ids alternativeIdentifiers) error {
+	for _, id := range ids.Identifiers {
+		if field, found := fieldsForAuthorities[id.Authority]; found {
+			return fmt.Errorf("identifier %s from %s belongs in %s rather than identifiers", id.IdentifierValue, id.Authority, field)
+		}
+	}
+	return nil
+}
+
+// identifiersInOrder sorts identifiers by authority then value, so they are read back in the same order every time
+type identifiersInOrder []identifier
+
+func (ids identifiersInOrder) Len() int      { return len(ids) }
+func (ids identifiersInOrder) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+func (ids identifiersInOrder) Less(i, j int) bool {
+	if ids[i].Authority != ids[j].Authority {
+		return ids[i].Authority < ids[j].Authority
+	}
+	return ids[i].IdentifierValue < ids[j].IdentifierValue
+}
+
+func sortIdentifiers(ids []identifier) {
+	sort.Sort(identifiersInOrder(ids))
+}
+
+func validateWikidataAndSameAs(ids alternativeIdentifiers) error {
+	if ids.WikidataIdentifier != "" && !wikidataQIDRegex.MatchString(ids.WikidataIdentifier) {
+		return fmt.Errorf("wikidataIdentifier %q is not a QID such as Q42", ids.WikidataIdentifier)
+	}
+	for _, uri := range ids.SameAs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("sameAs %q is not an absolute URI", uri)
+		}
+	}
+	return nil
+}
+
+// authorityRegistry maps between authority URIs and Identifier labels in both directions
+type authorityRegistry struct {
+	labels      map[string]string
+	authorities map[string]string
+}
+
+func newAuthorityRegistry(authorities []Authority) (authorityRegistry, error) {
+	r := authorityRegistry{
+		labels:      make(map[string]string, len(authorities)),
+		authorities: make(map[string]string, len(authorities)),
+	}
+
+	for _, a := range authorities {
+		if a.URI == "" {
+			return authorityRegistry{}, fmt.Errorf("identifier authority with label %s has no uri", a.Label)
+		}
+		if !authorityLabelRegex.MatchString(a.Label) {
+			return authorityRegistry{}, fmt.Errorf("label %q for identifier authority %s must be alphanumeric and end in Identifier", a.Label, a.URI)
+		}
+		if existing, found := r.labels[a.URI]; found && existing != a.Label {
+			if err := canReplace(a, existing, a.URI); err != nil {
+				return authorityRegistry{}, err
+			}
+			delete(r.authorities, existing)
+		}
+		if existing, found := r.authorities[a.Label]; found && existing != a.URI {
+			if err := canReplace(a, a.Label, existing); err != nil {
+				return authorityRegistry{}, err
+			}
+			delete(r.labels, existing)
+		}
+		r.labels[a.URI] = a.Label
+		r.authorities[a.Label] = a.URI
+	}
+
+	return r, nil
+}
+
+// canReplace checks that a may take the place of the authority registered before it with label and uri. Without
+// Override, a clash is far more likely a mistake than a wish to relabel, which leaves identifiers already written
+// under the old label unread. The authorities with fields of their own are known to other services by their labels,
+// so can never be replaced.
+func canReplace(a Authority, label string, uri string) error {
+	if !a.Override {
+		return fmt.Errorf("identifier authority %s with label %s clashes with %s with label %s, set override to replace it", a.URI, a.Label, uri, label)
+	}
+	if _, found := fieldsForAuthorities[uri]; found {
+		return fmt.Errorf("identifier authority %s with label %s cannot be replaced", uri, label)
+	}
+	return nil
+}
+
+func (r authorityRegistry) label(authority string) (string, bool) {
+	label, found := r.labels[authority]
+	return label, found
+}
+
+// authority finds the authority for an Identifier node from its labels
+func (r authorityRegistry) authority(labels []string) (string, bool) {
+	for _, l := range labels {
+		if authority, found := r.authorities[l]; found {
+			return authority, true
+		}
+	}
+	return "", false
+}
+
+// constraints are the uniqueness constraints needed for every registered authority
+func (r authorityRegistry) constraints() map[string]string {
+	constraints := make(map[string]string, len(r.authorities))
+	for label := range r.authorities {
+		constraints[label] = "value"
+	}
+	return constraints
+}
@@ -0,0 +1,100 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorityRegistryMapsBothWays(t *testing.T) {
+	assert := assert.New(t)
+
+	registry, err := newAuthorityRegistry(DefaultAuthorities())
+	assert.NoError(err)
+
+	label, found := registry.label(orcidAuthority)
+	assert.True(found)
+	assert.Equal("ORCIDIdentifier", label)
+
+	authority, found := registry.authority([]string{"Identifier", "ORCIDIdentifier"})
+	assert.True(found)
+	assert.Equal(orcidAuthority, authority)
+
+	assert.Equal("value", registry.constraints()[tmeIdentifierLabel])
+}
+
+func TestAuthorityRegistryRejectsUnsafeOrClashingLabels(t *testing.T) {
+	for _, authorities := range [][]Authority{
+		{{URI: "http://example.com/a", Label: "Bad Label`) DETACH DELETE n //Identifier"}},
+		{{URI: "http://example.com/a", Label: "NotAnIdentifierLabel"}},
+		{{URI: "", Label: "ExampleIdentifier"}},
+		{{URI: "http://example.com/a", Label: "ExampleIdentifier"}, {URI: "http://example.com/b", Label: "ExampleIdentifier"}},
+		append(DefaultAuthorities(), Authority{URI: orcidAuthority, Label: "OrcidIdentifier"}),
+		append(DefaultAuthorities(), Authority{URI: "http://example.com/orcid", Label: "ORCIDIdentifier"}),
+		append(DefaultAuthorities(), Authority{URI: tmeAuthority, Label: "TmeIdentifier", Override: true}),
+		append(DefaultAuthorities(), Authority{URI: "http://example.com/upp", Label: uppIdentifierLabel, Override: true}),
+	} {
+		_, err := newAuthorityRegistry(authorities)
+		assert.Error(t, err, "Expected %v to be rejected", authorities)
+	}
+}
+
+func TestOverridingAuthoritiesReplaceTheDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newAuthorityRegistry(append(DefaultAuthorities(),
+		Authority{URI: orcidAuthority, Label: "OrcidIdentifier", Override: true},
+		Authority{URI: "http://example.com/lei", Label: "LEIIdentifier", Override: true},
+		Authority{URI: leiAuthority, Label: "LEIIdentifier"},
+	))
+	assert.Error(err, "The LEI authority was replaced, so its label cannot be given to it again without override")
+
+	registry, err := newAuthorityRegistry(append(DefaultAuthorities(),
+		Authority{URI: orcidAuthority, Label: "OrcidIdentifier", Override: true},
+		Authority{URI: "http://example.com/lei", Label: "LEIIdentifier", Override: true},
+	))
+	assert.NoError(err)
+
+	label, _ := registry.label(orcidAuthority)
+	assert.Equal("OrcidIdentifier", label)
+	_, found := registry.authority([]string{"Identifier", "ORCIDIdentifier"})
+	assert.False(found, "The old ORCID label should no longer be read")
+
+	authority, _ := registry.authority([]string{"Identifier", "LEIIdentifier"})
+	assert.Equal("http://example.com/lei", authority)
+	_, found = registry.label(leiAuthority)
+	assert.False(found, "The replaced LEI authority should no longer be known")
+}
+
+func TestValidateWikidataAndSameAs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(validateWikidataAndSameAs(alternativeIdentifiers{WikidataIdentifier: "Q42", SameAs: []string{"http://dbpedia.org/resource/Douglas_Adams"}}))
+	assert.Error(validateWikidataAndSameAs(alternativeIdentifiers{WikidataIdentifier: "42"}))
+	assert.Error(validateWikidataAndSameAs(alternativeIdentifiers{WikidataIdentifier: "Q042"}))
+	assert.Error(validateWikidataAndSameAs(alternativeIdentifiers{SameAs: []string{"Douglas_Adams"}}))
+}
+
+func TestIdentifiersWithFieldsOfTheirOwnAreRejectedFromTheList(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(validateIdentifiers(alternativeIdentifiers{Identifiers: []identifier{{orcidAuthority, "0000-0002-1825-0097"}}}))
+	for _, authority := range []string{tmeAuthority, uppAuthority, factsetAuthority, wikidataAuthority, sameAsAuthority} {
+		assert.Error(validateIdentifiers(alternativeIdentifiers{Identifiers: []identifier{{authority, "1"}}}), authority)
+	}
+}
+
+func TestIdentifiersAreSortedByAuthorityThenValue(t *testing.T) {
+	ids := []identifier{
+		{orcidAuthority, "0000-0002-1825-0097"},
+		{companiesHouseOfficerAuthority, "b"},
+		{companiesHouseOfficerAuthority, "Ab1cD2eF3gH4iJ5kL6mN7oP8qR9"},
+	}
+	sortIdentifiers(ids)
+
+	assert.Equal(t, []identifier{
+		{companiesHouseOfficerAuthority, "Ab1cD2eF3gH4iJ5kL6mN7oP8qR9"},
+		{companiesHouseOfficerAuthority, "b"},
+		{orcidAuthority, "0000-0002-1825-0097"},
+	}, ids)
+}
@@ -0,0 +1,183 @@
+package people
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerSettings say when to stop sending batches to Neo4j. The breaker opens when at least ErrorRate of the
+// batches in a Window fail because Neo4j is unreachable, unavailable or too slow, as long as there were at least
+// MinRequests of them. While open batches fail straight away, until OpenFor has passed and a single batch is let
+// through to see whether Neo4j has recovered. An ErrorRate of 0 turns the breaker off.
+type CircuitBreakerSettings struct {
+	ErrorRate   float64
+	MinRequests int
+	Window      time.Duration
+	OpenFor     time.Duration
+}
+
+// DefaultCircuitBreakerSettings open the breaker when half the batches in 10 seconds fail
+func DefaultCircuitBreakerSettings() CircuitBreakerSettings {
+	return CircuitBreakerSettings{
+		ErrorRate:   0.5,
+		MinRequests: 20,
+		Window:      10 * time.Second,
+		OpenFor:     30 * time.Second,
+	}
+}
+
+// WithCircuitBreaker returns the service with a new circuit breaker using settings instead of the defaults
+func (s service) WithCircuitBreaker(settings CircuitBreakerSettings) service {
+	s.breaker = newCircuitBreaker(settings)
+	return s
+}
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// circuitOpenError is returned instead of running a batch while the breaker is open
+type circuitOpenError struct {
+	retryAfter time.Duration
+}
+
+func (e circuitOpenError) Error() string {
+	return fmt.Sprintf("Neo4j is failing, so requests to it are stopped for the next %s", e.retryAfter)
+}
+
+// circuitBreaker is shared by every copy of the service, so is nil when turned off rather than being a value
+type circuitBreaker struct {
+	sync.Mutex
+	settings CircuitBreakerSettings
+	now      func() time.Time
+
+	state       string
+	windowStart time.Time
+	requests    int
+	failures    int
+	openUntil   time.Time
+	probing     bool
+}
+
+func newCircuitBreaker(settings CircuitBreakerSettings) *circuitBreaker {
+	if settings.ErrorRate <= 0 {
+		return nil
+	}
+	return &circuitBreaker{settings: settings, now: time.Now, state: breakerClosed}
+}
+
+// allow returns a circuitOpenError if the batch should not be run
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case breakerOpen:
+		if now.Before(b.openUntil) {
+			return circuitOpenError{b.openUntil.Sub(now)}
+		}
+		b.transition(breakerHalfOpen)
+		b.probing = true
+	case breakerHalfOpen:
+		// only the one batch is let through until it is known whether Neo4j has recovered
+		if b.probing {
+			return circuitOpenError{time.Second}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record counts the outcome of a batch which allow let through
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	if err == context.Canceled {
+		// a client going away says nothing about Neo4j, so the next batch is let through to find out instead
+		b.probing = false
+		return
+	}
+
+	failed := isNeo4jFailure(err)
+	now := b.now()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.trip(now)
+		} else {
+			b.transition(breakerClosed)
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) > b.settings.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.settings.MinRequests && float64(b.failures) >= b.settings.ErrorRate*float64(b.requests) {
+		b.trip(now)
+	}
+}
+
+// isNeo4jFailure reports whether the error says Neo4j is unreachable, unavailable or too slow, including errors
+// which are not safe to retry
+func isNeo4jFailure(err error) bool {
+	if isTransient(err) || err == context.DeadlineExceeded {
+		return true
+	}
+	_, isNetError := err.(net.Error)
+	return isNetError
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	b.openUntil = now.Add(b.settings.OpenFor)
+	b.transition(breakerOpen)
+}
+
+func (b *circuitBreaker) transition(state string) {
+	if b.state != state {
+		breakerTransitions.WithLabelValues(state).Inc()
+	}
+	b.state = state
+}
+
+// status describes the breaker's state for the health check, which fails while it is not closed
+func (b *circuitBreaker) status() (string, error) {
+	if b == nil {
+		return "Circuit breaker is turned off", nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		return "", fmt.Errorf("circuit breaker is open after %d of %d batches failed, and will let a batch through in %s",
+			b.failures, b.requests, b.openUntil.Sub(b.now()))
+	case breakerHalfOpen:
+		return "", fmt.Errorf("circuit breaker is half open, waiting to see whether Neo4j has recovered")
+	}
+	return fmt.Sprintf("Circuit breaker is closed, %d of %d batches failed in the last %s", b.failures, b.requests, b.settings.Window), nil
+}
+
+// CheckCircuitBreaker fails while requests to Neo4j are being stopped because too many have failed
+func (s service) CheckCircuitBreaker() (string, error) {
+	return s.breaker.status()
+}
@@ -0,0 +1,140 @@
+package people
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/stretchr/testify/assert"
+)
+
+var unavailable = rwapi.ConstraintOrTransactionError{Message: "Transaction failed", Details: []string{"Neo.TransientError.General.DatabaseUnavailable: Database not available"}}
+
+func testBreaker() (*circuitBreaker, *time.Time) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(CircuitBreakerSettings{ErrorRate: 0.5, MinRequests: 4, Window: 10 * time.Second, OpenFor: 30 * time.Second})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerOpensAtTheErrorRate(t *testing.T) {
+	b, _ := testBreaker()
+	for _, err := range []error{nil, unavailable, nil} {
+		assert.NoError(t, b.allow())
+		b.record(err)
+	}
+	assert.NoError(t, b.allow(), "too few requests to judge")
+	b.record(unavailable)
+
+	err := b.allow()
+	assert.Equal(t, circuitOpenError{30 * time.Second}, err)
+	_, err = b.status()
+	assert.Error(t, err)
+}
+
+func TestBreakerIgnoresErrorsFromNeo4jWorking(t *testing.T) {
+	b, _ := testBreaker()
+	for i := 0; i < 10; i++ {
+		b.record(rwapi.ConstraintOrTransactionError{Message: "Transaction failed", Details: []string{"Neo.ClientError.Schema.ConstraintValidationFailed: Node(4) already exists with label `Person`"}})
+		b.record(context.Canceled)
+		b.record(errors.New("json: cannot unmarshal"))
+	}
+	assert.NoError(t, b.allow())
+}
+
+func TestBreakerCountsTimeouts(t *testing.T) {
+	b, _ := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(context.DeadlineExceeded)
+	}
+	assert.Error(t, b.allow())
+}
+
+func TestBreakerCountsBatchesSentBeforeNeo4jIsConnected(t *testing.T) {
+	b, _ := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(notConnectedError{errors.New("connection refused")})
+	}
+	assert.Error(t, b.allow())
+}
+
+func TestBreakerErrorsAreForgottenAfterTheWindow(t *testing.T) {
+	b, now := testBreaker()
+	b.record(unavailable)
+	b.record(unavailable)
+	*now = now.Add(11 * time.Second)
+	b.record(nil)
+	b.record(nil)
+	b.record(unavailable)
+	b.record(nil)
+	assert.NoError(t, b.allow())
+}
+
+func TestBreakerLetsOneBatchThroughAfterOpening(t *testing.T) {
+	b, now := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(unavailable)
+	}
+	*now = now.Add(31 * time.Second)
+
+	assert.NoError(t, b.allow())
+	assert.Error(t, b.allow(), "only one batch while half open")
+	b.record(unavailable)
+	assert.Equal(t, circuitOpenError{30 * time.Second}, b.allow(), "a failure opens it again")
+
+	*now = now.Add(31 * time.Second)
+	assert.NoError(t, b.allow())
+	b.record(nil)
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+	_, err := b.status()
+	assert.NoError(t, err)
+}
+
+func TestBreakerLetsAnotherBatchThroughWhenTheProbeIsCancelled(t *testing.T) {
+	b, now := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(unavailable)
+	}
+	*now = now.Add(31 * time.Second)
+
+	assert.NoError(t, b.allow())
+	b.record(context.Canceled)
+	assert.Equal(t, breakerHalfOpen, b.state, "a cancelled batch says nothing about Neo4j")
+	assert.NoError(t, b.allow(), "the next batch should find out instead")
+	b.record(nil)
+	assert.Equal(t, breakerClosed, b.state)
+}
+
+func TestHealthChecksBypassTheBreaker(t *testing.T) {
+	reached := errors.New("reached Neo4j")
+	s := NewCypherPeopleService(failingConnection{err: reached})
+	s.breaker, _ = testBreaker()
+	s.breaker.trip(s.breaker.now())
+
+	_, err := s.CheckPeopleLoaded()
+	assert.Equal(t, reached, err, "the check should reach Neo4j while the breaker is open")
+	_, err = s.CheckSchema()
+	assert.Equal(t, reached, err)
+	assert.Equal(t, 0, s.breaker.requests, "the checks should not count towards the breaker")
+}
+
+func TestBreakerCanBeTurnedOff(t *testing.T) {
+	s := NewCypherPeopleService(stubConnection{}).WithCircuitBreaker(CircuitBreakerSettings{})
+	for i := 0; i < 100; i++ {
+		s.breaker.record(unavailable)
+	}
+	assert.NoError(t, s.breaker.allow())
+	_, err := s.CheckCircuitBreaker()
+	assert.NoError(t, err)
+}
+
+func TestOpenBreakerRespondsWithRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeServiceError(w, circuitOpenError{1500 * time.Millisecond})
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
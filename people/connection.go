@@ -0,0 +1,161 @@
+package people
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/neo-utils-go/neoutils"
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/jmcvetta/neoism"
+)
+
+// reconnectDelay is how long a failed connection is remembered before the next batch tries to connect again, so a
+// Neo4j which is down is not asked once for every request
+const reconnectDelay = time.Second
+
+// notConnectedError is returned for a batch which could not be sent because there is no connection to Neo4j. Nothing
+// reached Neo4j, so it is always safe to run the batch again.
+type notConnectedError struct {
+	err error
+}
+
+func (e notConnectedError) Error() string {
+	return "not connected to neo4j: " + e.err.Error()
+}
+
+// Connect connects to Neo4j like neoutils.Connect, but keeps the status codes of the errors Neo4j gives for a failed
+// transaction, which neoutils drops, so transient errors are told apart by code. Each is kept at the start of a
+// detail of the rwapi.ConstraintOrTransactionError, e.g. "Neo.TransientError.Transaction.DeadlockDetected: ...".
+//
+// With conf.BackgroundConnect the connection is made by the first batch to need it, and every batch fails with a
+// notConnectedError until Neo4j can be reached. Otherwise Connect fails if Neo4j cannot be reached.
+func Connect(neoURL string, conf *neoutils.ConnectionConfig) (neoutils.NeoConnection, error) {
+	if conf == nil {
+		conf = neoutils.DefaultConnectionConfig()
+	}
+	parsed, err := url.Parse(neoURL)
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("inappropriate url " + neoURL)
+	}
+
+	c := &connection{url: neoURL, conf: *conf}
+	if !conf.BackgroundConnect {
+		if _, err := c.connected(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// connection connects to Neo4j when it is first needed, and again after failing to
+type connection struct {
+	url  string
+	conf neoutils.ConnectionConfig
+
+	lk         sync.Mutex
+	db         *neoism.Database
+	runner     neoutils.CypherRunner
+	lastErr    error
+	lastFailed time.Time
+}
+
+func (c *connection) CypherBatch(queries []*neoism.CypherQuery) error {
+	runner, err := c.connected()
+	if err != nil {
+		return err
+	}
+	return runner.CypherBatch(queries)
+}
+
+func (c *connection) EnsureIndexes(indexes map[string]string) error {
+	if _, err := c.connected(); err != nil {
+		return err
+	}
+	return neoutils.EnsureIndexes(c.db, indexes)
+}
+
+func (c *connection) EnsureConstraints(constraints map[string]string) error {
+	if _, err := c.connected(); err != nil {
+		return err
+	}
+	return neoutils.EnsureConstraints(c.db, constraints)
+}
+
+func (c *connection) String() string {
+	return c.url
+}
+
+// connected is the runner for the connection, connecting first if need be
+func (c *connection) connected() (neoutils.CypherRunner, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if c.runner != nil {
+		return c.runner, nil
+	}
+	if c.lastErr != nil && time.Since(c.lastFailed) < reconnectDelay {
+		return nil, c.lastErr
+	}
+
+	db, err := neoism.Connect(c.url)
+	if err != nil {
+		c.lastErr, c.lastFailed = notConnectedError{err}, time.Now()
+		return nil, c.lastErr
+	}
+	if c.conf.HTTPClient != nil {
+		db.Session.Client = c.conf.HTTPClient
+	}
+	db.Session.Header.Set("User-Agent", filepath.Base(os.Args[0])+" (using neoism)")
+
+	c.db = db
+	c.runner = transactionalRunner{db}
+	if c.conf.BatchSize > 0 {
+		c.runner = neoutils.NewBatchCypherRunner(c.runner, c.conf.BatchSize)
+	}
+	return c.runner, nil
+}
+
+// transactionalRunner runs each batch in a transaction, like neoutils.TransactionalCypherRunner but keeping the codes
+type transactionalRunner struct {
+	db *neoism.Database
+}
+
+func (r transactionalRunner) CypherBatch(queries []*neoism.CypherQuery) error {
+	tx, err := r.db.Begin(queries)
+	if err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return transactionError(tx, err)
+	}
+	return tx.Commit()
+}
+
+// transactionError is the error for a transaction which failed to begin, with the code and message of each error
+// Neo4j gave for its queries
+func transactionError(tx *neoism.Tx, err error) error {
+	if err != neoism.TxQueryError || tx == nil {
+		return err
+	}
+	txErr := rwapi.ConstraintOrTransactionError{Message: err.Error()}
+	for _, e := range tx.Errors {
+		txErr.Details = append(txErr.Details, e.Code+": "+e.Message)
+	}
+	return txErr
+}
+
+// neo4jErrorCodes are the status codes kept at the start of the details of a failed transaction
+func neo4jErrorCodes(err rwapi.ConstraintOrTransactionError) []string {
+	var codes []string
+	for _, detail := range err.Details {
+		if i := strings.Index(detail, ": "); i > 0 && strings.HasPrefix(detail, "Neo.") {
+			codes = append(codes, detail[:i])
+		}
+	}
+	return codes
+}
@@ -0,0 +1,42 @@
+package people
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailedTransactionsKeepTheirCodes(t *testing.T) {
+	tx := &neoism.Tx{Errors: []neoism.TxError{
+		{Code: "Neo.TransientError.Transaction.DeadlockDetected", Message: "can't acquire ExclusiveLock"},
+		{Code: "Neo.ClientError.Schema.ConstraintValidationFailed", Message: "Node(4) already exists with label `Person`"},
+	}}
+
+	err := transactionError(tx, neoism.TxQueryError)
+
+	txErr, ok := err.(rwapi.ConstraintOrTransactionError)
+	assert.True(t, ok, "failed transactions are still conflicts")
+	assert.Equal(t, []string{"Neo.TransientError.Transaction.DeadlockDetected", "Neo.ClientError.Schema.ConstraintValidationFailed"}, neo4jErrorCodes(txErr))
+	assert.True(t, isTransient(err))
+}
+
+func TestOtherErrorsAreNotTransactionErrors(t *testing.T) {
+	other := errors.New("unexpected end of JSON input")
+	assert.Equal(t, other, transactionError(&neoism.Tx{}, other))
+}
+
+func TestConnectingNeedsAHost(t *testing.T) {
+	_, err := Connect("localhost:7474/db/data", nil)
+	assert.Error(t, err)
+}
+
+func TestFailedConnectionsAreNotRetriedAtOnce(t *testing.T) {
+	failed := notConnectedError{errors.New("connection refused")}
+	c := &connection{url: "http://localhost:7474/db/data", lastErr: failed, lastFailed: time.Now()}
+
+	assert.Equal(t, failed, c.CypherBatch(nil))
+}
@@ -0,0 +1,73 @@
+package people
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// contentVersion1 returns a single factsetIdentifier, as clients written before people could have several expect
+	contentVersion1 = 1
+	// contentVersion2 returns every Factset identifier in factsetIdentifiers
+	contentVersion2 = 2
+)
+
+// requestedContentVersion reads the version parameter of the Accept header, e.g. "application/json; version=2",
+// defaulting to version 1 for existing clients
+func requestedContentVersion(r *http.Request) int {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if v, err := strconv.Atoi(params["version"]); err == nil && v >= contentVersion1 && v <= contentVersion2 {
+			return v
+		}
+	}
+	return contentVersion1
+}
+
+// normalised moves a factsetIdentifier supplied the old way into factsetIdentifiers
+func (ids alternativeIdentifiers) normalised() alternativeIdentifiers {
+	if ids.FactsetIdentifier == "" {
+		return ids
+	}
+
+	found := false
+	for _, value := range ids.FactsetIdentifiers {
+		if value == ids.FactsetIdentifier {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		ids.FactsetIdentifiers = append([]string{ids.FactsetIdentifier}, ids.FactsetIdentifiers...)
+	}
+	ids.FactsetIdentifier = ""
+	return ids
+}
+
+// withFactsetIdentifierFirst moves the Factset identifier which was first when the person was written back to the
+// front of factsetIdentifiers, as they are read in order of value
+func (ids alternativeIdentifiers) withFactsetIdentifierFirst(first string) alternativeIdentifiers {
+	for i, value := range ids.FactsetIdentifiers {
+		if value == first {
+			ids.FactsetIdentifiers = append(append([]string{value}, ids.FactsetIdentifiers[:i]...), ids.FactsetIdentifiers[i+1:]...)
+			break
+		}
+	}
+	return ids
+}
+
+// asVersion1 returns the identifiers in the shape clients expected before people could have several Factset
+// identifiers. Only the first is returned, which is the one given as factsetIdentifier if there was one.
+func (ids alternativeIdentifiers) asVersion1() alternativeIdentifiers {
+	if len(ids.FactsetIdentifiers) > 0 {
+		ids.FactsetIdentifier = ids.FactsetIdentifiers[0]
+	}
+	ids.FactsetIdentifiers = nil
+	return ids
+}
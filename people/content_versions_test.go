@@ -0,0 +1,59 @@
+package people
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestedContentVersion(t *testing.T) {
+	for accept, expected := range map[string]int{
+		"":                                      contentVersion1,
+		"application/json":                      contentVersion1,
+		"application/json; version=2":           contentVersion2,
+		"text/html, application/json;version=2": contentVersion2,
+		"application/json; version=99":          contentVersion1,
+		"application/json; version=two":         contentVersion1,
+	} {
+		r, _ := http.NewRequest("GET", "/people/180cec41-23fa-4148-806b-0602924e6858", nil)
+		r.Header.Set("Accept", accept)
+		assert.Equal(t, expected, requestedContentVersion(r), "Wrong version for Accept %q", accept)
+	}
+}
+
+func TestFactsetIdentifiersInBothShapes(t *testing.T) {
+	assert := assert.New(t)
+
+	ids := alternativeIdentifiers{FactsetIdentifier: "A", FactsetIdentifiers: []string{"B"}}.normalised()
+	assert.Equal([]string{"A", "B"}, ids.FactsetIdentifiers)
+	assert.Empty(ids.FactsetIdentifier)
+
+	legacy := ids.asVersion1()
+	assert.Equal("A", legacy.FactsetIdentifier)
+	assert.Nil(legacy.FactsetIdentifiers)
+}
+
+func TestHashOfPeopleWrittenWithFactsetIdentifierIsUnchanged(t *testing.T) {
+	assert := assert.New(t)
+
+	p, _, err := service{}.DecodeJSON(json.NewDecoder(strings.NewReader(`{"uuid":"3fa70485-3a57-3b9b-9449-774b001cd965","prefLabel":"Robert Addington","alternativeIdentifiers":{"uuids":["3fa70485-3a57-3b9b-9449-774b001cd965"],"factsetIdentifier":"000BJG-E"}}`)))
+	assert.NoError(err)
+	assert.Equal("000BJG-E", p.(person).AlternativeIdentifiers.FactsetIdentifier)
+	assert.Nil(p.(person).AlternativeIdentifiers.FactsetIdentifiers)
+
+	hash, err := writeHash(p)
+	assert.NoError(err)
+	assert.Equal("346e499618c0638f2bcd62eaf261d187", hash, "Should be the hash written before factsetIdentifiers were added")
+}
+
+func TestFactsetIdentifierWrittenFirstIsReadFirst(t *testing.T) {
+	ids := alternativeIdentifiers{FactsetIdentifiers: []string{"A", "B", "C"}}
+
+	assert.Equal(t, []string{"C", "A", "B"}, ids.withFactsetIdentifierFirst("C").FactsetIdentifiers)
+	assert.Equal(t, []string{"A", "B", "C"}, ids.withFactsetIdentifierFirst("A").FactsetIdentifiers)
+	assert.Equal(t, []string{"A", "B", "C"}, ids.withFactsetIdentifierFirst("").FactsetIdentifiers, "People written before it was recorded keep the order of value")
+	assert.Equal(t, "C", ids.withFactsetIdentifierFirst("C").asVersion1().FactsetIdentifier)
+}
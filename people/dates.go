@@ -0,0 +1,78 @@
+package people
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	yearPrecision  = "year"
+	monthPrecision = "month"
+	dayPrecision   = "day"
+)
+
+// partialDateLayouts are the ISO-8601 forms accepted for dates of birth and death, from least to most precise
+var partialDateLayouts = []struct {
+	layout    string
+	precision string
+}{
+	{"2006", yearPrecision},
+	{"2006-01", monthPrecision},
+	{"2006-01-02", dayPrecision},
+}
+
+// partialDate is a date known to the year, month or day
+type partialDate struct {
+	date      time.Time
+	precision string
+}
+
+func parsePartialDate(value string) (partialDate, error) {
+	for _, l := range partialDateLayouts {
+		if len(value) != len(l.layout) {
+			continue
+		}
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return partialDate{t, l.precision}, nil
+		}
+	}
+	return partialDate{}, fmt.Errorf("%q is not a date in the form YYYY, YYYY-MM or YYYY-MM-DD", value)
+}
+
+// before reports whether d is definitely before other, comparing only as precisely as the less precise date allows
+func (d partialDate) before(other partialDate) bool {
+	layout := partialDateLayouts[0].layout
+	for _, l := range partialDateLayouts {
+		if l.precision == d.precision || l.precision == other.precision {
+			layout = l.layout
+			break
+		}
+	}
+	return d.date.Format(layout) < other.date.Format(layout)
+}
+
+// validateLifeDates checks the dates of birth and death, and that they agree with each other and with birthYear
+func validateLifeDates(p person) (birth partialDate, death partialDate, err error) {
+	if p.DateOfBirth != "" {
+		if birth, err = parsePartialDate(p.DateOfBirth); err != nil {
+			return birth, death, fmt.Errorf("invalid dateOfBirth: %v", err)
+		}
+		if p.BirthYear != 0 && p.BirthYear != birth.date.Year() {
+			return birth, death, fmt.Errorf("birthYear %d does not match dateOfBirth %s", p.BirthYear, p.DateOfBirth)
+		}
+	}
+
+	if p.DateOfDeath != "" {
+		if death, err = parsePartialDate(p.DateOfDeath); err != nil {
+			return birth, death, fmt.Errorf("invalid dateOfDeath: %v", err)
+		}
+		if p.DateOfBirth != "" && death.before(birth) {
+			return birth, death, fmt.Errorf("dateOfDeath %s is before dateOfBirth %s", p.DateOfDeath, p.DateOfBirth)
+		}
+		if p.DateOfBirth == "" && p.BirthYear != 0 && death.date.Year() < p.BirthYear {
+			return birth, death, fmt.Errorf("dateOfDeath %s is before birthYear %d", p.DateOfDeath, p.BirthYear)
+		}
+	}
+
+	return birth, death, nil
+}
@@ -0,0 +1,49 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePartialDateAcceptsEachPrecision(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, precision := range map[string]string{
+		"1974":       yearPrecision,
+		"1974-03":    monthPrecision,
+		"1974-03-21": dayPrecision,
+	} {
+		d, err := parsePartialDate(value)
+		assert.NoError(err, "Failed to parse %s", value)
+		assert.Equal(precision, d.precision, "Wrong precision for %s", value)
+		assert.Equal(1974, d.date.Year())
+	}
+}
+
+func TestParsePartialDateRejectsOtherForms(t *testing.T) {
+	for _, value := range []string{"", "74", "1974-3", "1974-13", "1974-02-30", "21/03/1974", "1974-03-21T00:00:00Z"} {
+		_, err := parsePartialDate(value)
+		assert.Error(t, err, "Expected %q to be rejected", value)
+	}
+}
+
+func TestValidateLifeDates(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, err := validateLifeDates(person{DateOfBirth: "1950-06", DateOfDeath: "1950"})
+	assert.NoError(err, "Dates that cannot be ordered at the shared precision are allowed")
+
+	_, _, err = validateLifeDates(person{DateOfBirth: "1950-06-02", DateOfDeath: "1950-06-01"})
+	assert.Error(err, "Death before birth should be rejected")
+
+	_, _, err = validateLifeDates(person{BirthYear: 1951, DateOfDeath: "1950-12"})
+	assert.Error(err, "Death before birthYear should be rejected")
+
+	_, _, err = validateLifeDates(person{BirthYear: 1951, DateOfBirth: "1950-12"})
+	assert.Error(err, "birthYear disagreeing with dateOfBirth should be rejected")
+
+	birth, _, err := validateLifeDates(person{BirthYear: 1950, DateOfBirth: "1950-12"})
+	assert.NoError(err)
+	assert.Equal(monthPrecision, birth.precision)
+}
@@ -0,0 +1,165 @@
+package people
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// allowedDescriptionTags are the FT body XML elements renderers understand, with the attributes each may keep
+var allowedDescriptionTags = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"strong":     nil,
+	"em":         nil,
+	"b":          nil,
+	"i":          nil,
+	"sub":        nil,
+	"sup":        nil,
+	"ul":         nil,
+	"ol":         nil,
+	"li":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"blockquote": nil,
+	"a":          {"href", "title"},
+	"ft-content": {"url", "type"},
+}
+
+// droppedDescriptionTags are removed along with everything inside them. Any other unknown element is removed but
+// its content kept.
+var droppedDescriptionTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+}
+
+// blockDescriptionTags separate words in the plain text derived from the markup
+var blockDescriptionTags = map[string]bool{
+	"p": true, "br": true, "li": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// sanitiseDescriptionXML checks descriptionXML is well formed, strips anything renderers should not be given, and
+// returns the result along with its plain text
+func sanitiseDescriptionXML(descriptionXML string) (string, string, error) {
+	d := xml.NewDecoder(strings.NewReader("<body>" + descriptionXML + "</body>"))
+	d.Entity = xml.HTMLEntity
+
+	var sanitised, text bytes.Buffer
+	// elements are written or skipped on the way in, and the same has to happen on the way out
+	var open []bool
+	dropping := 0
+
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("descriptionXML is not well formed: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(open) == 0 && t.Name.Local == "body" {
+				open = append(open, false)
+				continue
+			}
+			if dropping > 0 || droppedDescriptionTags[t.Name.Local] {
+				dropping++
+				open = append(open, false)
+				continue
+			}
+			attrs, allowed := allowedDescriptionTags[t.Name.Local]
+			open = append(open, allowed)
+			if blockDescriptionTags[t.Name.Local] {
+				text.WriteString(" ")
+			}
+			if allowed {
+				writeDescriptionStartElement(&sanitised, t, attrs)
+			}
+		case xml.EndElement:
+			written := open[len(open)-1]
+			open = open[:len(open)-1]
+			if dropping > 0 {
+				dropping--
+				continue
+			}
+			if blockDescriptionTags[t.Name.Local] {
+				text.WriteString(" ")
+			}
+			if written {
+				closeDescriptionElement(&sanitised, t.Name.Local)
+			}
+		case xml.CharData:
+			if dropping > 0 {
+				continue
+			}
+			text.Write(t)
+			sanitised.WriteString(escapeDescriptionText(string(t)))
+		}
+	}
+
+	return sanitised.String(), strings.Join(strings.Fields(text.String()), " "), nil
+}
+
+func writeDescriptionStartElement(w *bytes.Buffer, e xml.StartElement, allowedAttrs []string) {
+	w.WriteString("<" + e.Name.Local)
+	for _, attr := range e.Attr {
+		if !containsString(allowedAttrs, attr.Name.Local) || !safeDescriptionAttr(attr) {
+			continue
+		}
+		w.WriteString(" " + attr.Name.Local + `="`)
+		w.WriteString(strings.Replace(escapeDescriptionText(attr.Value), `"`, "&quot;", -1))
+		w.WriteString(`"`)
+	}
+	w.WriteString(">")
+}
+
+// closeDescriptionElement turns <br></br> back into <br/>
+func closeDescriptionElement(w *bytes.Buffer, name string) {
+	if name == "br" && bytes.HasSuffix(w.Bytes(), []byte("<br>")) {
+		w.Truncate(w.Len() - 1)
+		w.WriteString("/>")
+		return
+	}
+	w.WriteString("</" + name + ">")
+}
+
+// escapeDescriptionText only escapes what it must, unlike xml.EscapeText, so quotes and new lines are kept as written
+func escapeDescriptionText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// safeDescriptionAttr rejects links which are not http(s) or relative, such as javascript: ones
+func safeDescriptionAttr(attr xml.Attr) bool {
+	if attr.Name.Local != "href" && attr.Name.Local != "url" {
+		return true
+	}
+	u, err := url.Parse(strings.TrimSpace(attr.Value))
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "" || scheme == "http" || scheme == "https"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
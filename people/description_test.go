@@ -0,0 +1,36 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowedDescriptionXMLIsKept(t *testing.T) {
+	descriptionXML := `<p><strong>Richer</strong> description with <a href="http://www.ft.com/" title="FT">a link</a> &amp; "quotes"<br/>on two lines</p>`
+	sanitised, text, err := sanitiseDescriptionXML(descriptionXML)
+	assert.NoError(t, err)
+	assert.Equal(t, `<p><strong>Richer</strong> description with <a href="http://www.ft.com/" title="FT">a link</a> &amp; "quotes"<br/>on two lines</p>`, sanitised)
+	assert.Equal(t, `Richer description with a link & "quotes" on two lines`, text)
+}
+
+func TestScriptsAndUnknownTagsAreStripped(t *testing.T) {
+	descriptionXML := `<p onclick="steal()">Hello <script>alert("x")</script><blink>world</blink></p><a href="javascript:steal()">link</a>`
+	sanitised, text, err := sanitiseDescriptionXML(descriptionXML)
+	assert.NoError(t, err)
+	assert.Equal(t, `<p>Hello world</p><a>link</a>`, sanitised)
+	assert.Equal(t, "Hello world link", text)
+}
+
+func TestHTMLEntitiesAreAccepted(t *testing.T) {
+	sanitised, _, err := sanitiseDescriptionXML(`<p>a&nbsp;b &amp; c</p>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>a b &amp; c</p>", sanitised)
+}
+
+func TestMalformedDescriptionXMLIsRejected(t *testing.T) {
+	for _, descriptionXML := range []string{`<p>unclosed`, `<p><strong>crossed</p></strong>`, `</p>`} {
+		_, _, err := sanitiseDescriptionXML(descriptionXML)
+		assert.Error(t, err, descriptionXML)
+	}
+}
@@ -0,0 +1,240 @@
+package people
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jmcvetta/neoism"
+)
+
+// DefaultDuplicateConfidence leaves out pairs which only share a name, as namesakes are common
+const DefaultDuplicateConfidence = 0.5
+
+// keys shared by more people than this, such as very common names, are not used to find candidates, as comparing
+// every pair would take too long and say little
+const maxDuplicateCandidates = 50
+
+const (
+	sameNameScore         = 0.4
+	sameEmailScore        = 0.5
+	sameProfileScore      = 0.5
+	sameBirthYearScore    = 0.2
+	differentBirthYearMod = -0.4
+)
+
+// duplicate is a pair of people which are probably the same person, with why they are thought to be
+type duplicate struct {
+	UUIDs      []string `json:"uuids"`
+	Confidence float64  `json:"confidence"`
+	Reasons    []string `json:"reasons"`
+}
+
+type byConfidence []duplicate
+
+func (b byConfidence) Len() int { return len(b) }
+func (b byConfidence) Less(i, j int) bool {
+	if b[i].Confidence != b[j].Confidence {
+		return b[i].Confidence > b[j].Confidence
+	}
+	return b[i].UUIDs[0]+b[i].UUIDs[1] < b[j].UUIDs[0]+b[j].UUIDs[1]
+}
+func (b byConfidence) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+
+// duplicateCandidate is what a person is compared on
+type duplicateCandidate struct {
+	uuid      string
+	names     map[string]bool
+	birthYear int
+	email     string
+	profiles  map[string]bool
+}
+
+// Duplicates scans every person and reports pairs which are probably the same person, by normalised prefLabel and
+// aliases, birth year, and shared social profiles or email address, with at least minConfidence
+func (s service) Duplicates(minConfidence float64) ([]duplicate, error) {
+	candidates, err := s.duplicateCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string][]int)
+	for i, c := range candidates {
+		for _, key := range c.keys() {
+			byKey[key] = append(byKey[key], i)
+		}
+	}
+
+	compared := make(map[[2]int]bool)
+	duplicates := []duplicate{}
+	for _, group := range byKey {
+		if len(group) < 2 || len(group) > maxDuplicateCandidates {
+			continue
+		}
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				pair := [2]int{group[i], group[j]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				d := compareCandidates(candidates[pair[0]], candidates[pair[1]])
+				if d.Confidence >= minConfidence {
+					duplicates = append(duplicates, d)
+				}
+			}
+		}
+	}
+
+	sort.Sort(byConfidence(duplicates))
+	return duplicates, nil
+}
+
+func (s service) duplicateCandidates() ([]duplicateCandidate, error) {
+	batchSize := 4096
+	var candidates []duplicateCandidate
+
+	for skip := 0; ; skip += batchSize {
+		results := []storedPerson{}
+		readQuery := &neoism.CypherQuery{
+			Statement: `MATCH (p:Person)
+						RETURN p.uuid as uuid,
+							p.prefLabel as prefLabel,
+							p.aliases as aliases,
+							p.prefLabelValues as prefLabelValues,
+							p.aliasValues as aliasValues,
+							p.birthYear as birthYear,
+							p.emailAddress as emailAddress,
+							p.twitterHandle as twitterHandle,
+							p.facebookProfile as facebookProfile,
+							p.linkedinProfile as linkedinProfile,
+							p.profileNetworks as profileNetworks,
+							p.profileHandles as profileHandles,
+							p.profileUrls as profileUrls
+						ORDER BY uuid SKIP {skip} LIMIT {limit}`,
+			Parameters: map[string]interface{}{
+				"limit": batchSize,
+				"skip":  skip,
+			},
+			Result: &results,
+		}
+		if err := s.cypherBatch("", "duplicates", []*neoism.CypherQuery{readQuery}); err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			return candidates, nil
+		}
+		for _, result := range results {
+			candidates = append(candidates, newDuplicateCandidate(result))
+		}
+	}
+}
+
+func newDuplicateCandidate(stored storedPerson) duplicateCandidate {
+	c := duplicateCandidate{
+		uuid:      stored.UUID,
+		names:     make(map[string]bool),
+		birthYear: stored.BirthYear,
+		email:     strings.ToLower(strings.TrimSpace(stored.EmailAddress)),
+		profiles:  make(map[string]bool),
+	}
+
+	names := append([]string{stored.PrefLabel}, stored.Aliases...)
+	names = append(names, stored.PrefLabelValues...)
+	names = append(names, stored.AliasValues...)
+	for _, name := range names {
+		if n := normaliseName(name); n != "" {
+			c.names[n] = true
+		}
+	}
+
+	p := stored.person
+	p.Profiles = unflattenProfiles(stored.ProfileNetworks, stored.ProfileHandles, stored.ProfileURLs)
+	// people written before profiles were normalised only have the older fields, in whatever form they were sent
+	if normalised, err := normaliseProfiles(p); err == nil {
+		p = normalised
+	}
+	for _, profile := range p.Profiles {
+		c.profiles[profile.Network+" "+strings.ToLower(profile.Handle)] = true
+	}
+
+	return c
+}
+
+// keys are the values a candidate must share with another for the two to be compared
+func (c duplicateCandidate) keys() []string {
+	var keys []string
+	for name := range c.names {
+		keys = append(keys, "name:"+name)
+	}
+	if c.email != "" {
+		keys = append(keys, "email:"+c.email)
+	}
+	for profile := range c.profiles {
+		keys = append(keys, "profile:"+profile)
+	}
+	return keys
+}
+
+func compareCandidates(a duplicateCandidate, b duplicateCandidate) duplicate {
+	first, second := a, b
+	if second.uuid < first.uuid {
+		first, second = second, first
+	}
+	d := duplicate{UUIDs: []string{first.uuid, second.uuid}, Reasons: []string{}}
+	score := 0.0
+
+	for _, name := range sortedSetKeys(a.names) {
+		if b.names[name] {
+			score += sameNameScore
+			d.Reasons = append(d.Reasons, fmt.Sprintf("same name %q", name))
+			break
+		}
+	}
+
+	if a.email != "" && a.email == b.email {
+		score += sameEmailScore
+		d.Reasons = append(d.Reasons, fmt.Sprintf("same email address %s", a.email))
+	}
+
+	for _, profile := range sortedSetKeys(a.profiles) {
+		if b.profiles[profile] {
+			score += sameProfileScore
+			parts := strings.SplitN(profile, " ", 2)
+			d.Reasons = append(d.Reasons, fmt.Sprintf("same %s profile %s", parts[0], parts[1]))
+		}
+	}
+
+	if a.birthYear != 0 && b.birthYear != 0 {
+		if a.birthYear == b.birthYear {
+			score += sameBirthYearScore
+			d.Reasons = append(d.Reasons, fmt.Sprintf("same birth year %d", a.birthYear))
+		} else {
+			score += differentBirthYearMod
+			d.Reasons = append(d.Reasons, fmt.Sprintf("different birth years %d and %d", a.birthYear, b.birthYear))
+		}
+	}
+
+	d.Confidence = math.Max(0, math.Min(1, math.Floor(score*100+0.5)/100))
+	return d
+}
+
+// normaliseName lower cases a name and reduces it to its words, so punctuation and spacing do not matter
+func normaliseName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func sortedSetKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormaliseName(t *testing.T) {
+	assert.Equal(t, "robert w addington", normaliseName("  Robert W. Addington "))
+	assert.Equal(t, "安倍晋三", normaliseName("安倍晋三"))
+	assert.Equal(t, "", normaliseName("..."))
+}
+
+func TestCandidatesIncludeOlderProfileFieldsAndAliases(t *testing.T) {
+	c := newDuplicateCandidate(storedPerson{
+		person:          person{UUID: "1", PrefLabel: "Robert Addington", Aliases: []string{"Bob Addington"}, TwitterHandle: "twitter.com/RWA"},
+		PrefLabelValues: []string{"Robert Addington"},
+	})
+	assert.Equal(t, map[string]bool{"robert addington": true, "bob addington": true}, c.names)
+	assert.Equal(t, map[string]bool{"twitter @rwa": true}, c.profiles)
+}
+
+func TestCompareCandidates(t *testing.T) {
+	a := duplicateCandidate{uuid: "b", names: map[string]bool{"robert addington": true}, birthYear: 1974, email: "rwa@example.com", profiles: map[string]bool{}}
+	b := duplicateCandidate{uuid: "a", names: map[string]bool{"robert addington": true}, birthYear: 1974, profiles: map[string]bool{}}
+
+	d := compareCandidates(a, b)
+	assert.Equal(t, []string{"a", "b"}, d.UUIDs)
+	assert.Equal(t, 0.6, d.Confidence)
+	assert.Equal(t, []string{`same name "robert addington"`, "same birth year 1974"}, d.Reasons)
+
+	b.email = "rwa@example.com"
+	assert.Equal(t, 1.0, compareCandidates(a, b).Confidence)
+
+	b.email = ""
+	b.birthYear = 1950
+	assert.Equal(t, 0.0, compareCandidates(a, b).Confidence)
+}
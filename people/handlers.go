@@ -0,0 +1,316 @@
+package people
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Financial-Times/transactionid-utils-go"
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// PeopleHandler exposes the people service over HTTP
+type PeopleHandler struct {
+	service service
+	limiter *writeLimiter
+}
+
+// NewPeopleHandler returns a handler serving the read/write endpoints for people, as baseftrwapp used to, plus the
+// admin endpoints which baseftrwapp has no way of adding
+func NewPeopleHandler(s service) PeopleHandler {
+	return PeopleHandler{s, newWriteLimiter(DefaultWriteLimits())}
+}
+
+// RegisterHandlers adds the people endpoints to the router
+func (h PeopleHandler) RegisterHandlers(router *mux.Router) {
+	router.HandleFunc("/people/__count", traced("GET /people/__count", h.CountHandler)).Methods("GET")
+	router.HandleFunc("/people/__ids", traced("GET /people/__ids", h.IDsHandler)).Methods("GET")
+	router.HandleFunc("/people/__reconcile", traced("POST /people/__reconcile", h.ReconcileHandler))
+	router.HandleFunc("/people/__lookup", traced("GET /people/__lookup", h.LookupHandler)).Methods("GET")
+	router.HandleFunc("/people/__duplicates", traced("GET /people/__duplicates", h.DuplicatesHandler)).Methods("GET")
+	router.HandleFunc("/people/{uuid}/__impact", traced("GET /people/{uuid}/__impact", h.ImpactHandler)).Methods("GET")
+	router.HandleFunc("/people/{uuid}", traced("GET /people/{uuid}", h.GetHandler)).Methods("GET")
+	router.HandleFunc("/people/{uuid}", traced("PUT /people/{uuid}", h.limitWrites(h.PutHandler))).Methods("PUT")
+	router.HandleFunc("/people/{uuid}", traced("DELETE /people/{uuid}", h.limitWrites(h.DeleteHandler))).Methods("DELETE")
+}
+
+// PutHandler writes the person in the request body, which may be gzipped
+func (h PeopleHandler) PutHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+	w.Header().Add("Content-Type", "application/json")
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		unzipped, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer unzipped.Close()
+		body = unzipped
+	}
+
+	p, docUUID, err := h.service.DecodeJSON(json.NewDecoder(body))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if docUUID != uuid {
+		writeJSONError(w, fmt.Sprintf("Uuids from payload and request, respectively, do not match: '%v' '%v'", docUUID, uuid), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.WriteContext(r.Context(), p, tid); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetHandler returns the person as it was written. Clients asking for content version 2 get every Factset
+// identifier, everyone else gets the first. When the request has an Accept-Language header, prefLabel is replaced
+// by the best matching language tagged label.
+func (h PeopleHandler) GetHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+
+	p, found, err := h.service.ReadContext(r.Context(), uuid, tid)
+	w.Header().Add("Content-Type", "application/json")
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	version := requestedContentVersion(r)
+	if version == contentVersion1 {
+		legacy := p.(person)
+		legacy.AlternativeIdentifiers = legacy.AlternativeIdentifiers.asVersion1()
+		p = legacy
+	}
+	w.Header().Set("Content-Type", fmt.Sprintf("application/json; version=%d", version))
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Language")
+	if acceptLanguage := r.Header.Get("Accept-Language"); acceptLanguage != "" {
+		if localised, lang := p.(person).localised(acceptLanguage); lang != "" {
+			p = localised
+			w.Header().Set("Content-Language", lang)
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Errorf("Error on json encoding=%v", err)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DeleteHandler removes the person, leaving a bare Thing behind if anything else refers to it. The cascade query
+// parameter can ask for the person's memberships to be removed too.
+func (h PeopleHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	tid := transactionidutils.GetTransactionIDFromRequest(r)
+
+	deleted, err := h.service.DeleteWithCascadeContext(r.Context(), uuid, tid, r.URL.Query().Get("cascade"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if deleted {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// ImpactHandler lists what refers to the person, to show what a delete would leave behind. The cascade query
+// parameter is the same as for a delete.
+func (h PeopleHandler) ImpactHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	w.Header().Add("Content-Type", "application/json")
+
+	i, found, err := h.service.Impact(uuid, r.URL.Query().Get("cascade"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if !found {
+		writeJSONError(w, fmt.Sprintf("Person with uuid %s not found", uuid), http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(i); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CountHandler returns the number of people
+func (h PeopleHandler) CountHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := h.service.CountContext(r.Context())
+	w.Header().Add("Content-Type", "application/json")
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(count); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// IDsHandler streams the uuid and hash of every person as newline delimited JSON
+func (h PeopleHandler) IDsHandler(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	err := h.service.IDsContext(r.Context(), func(id rwapi.IDEntry) (bool, error) {
+		if err := enc.Encode(id); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		// the status has most likely already been sent, so all we can do is log
+		log.Errorf("Error streaming ids=%v", err)
+	}
+}
+
+// ReconcileHandler compares a newline delimited JSON stream of ids and hashes, as produced by the transformer's
+// __ids endpoint, with what is in Neo4j
+func (h PeopleHandler) ReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if r.Method != "POST" {
+		writeJSONError(w, fmt.Sprintf("Method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	next := func() (rwapi.IDEntry, bool, error) {
+		id := rwapi.IDEntry{}
+		if err := dec.Decode(&id); err == io.EOF {
+			return id, false, nil
+		} else if err != nil {
+			return id, false, requestError{err.Error()}
+		}
+		if id.ID == "" {
+			return id, false, requestError{"Entry without an id in request body"}
+		}
+		return id, true, nil
+	}
+
+	result, err := h.service.Reconcile(r.Context(), next)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// LookupHandler finds the uuid of a person from one of their alternative identifiers, given as the authority and
+// identifierValue query parameters
+func (h PeopleHandler) LookupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+
+	authority := r.URL.Query().Get("authority")
+	value := r.URL.Query().Get("identifierValue")
+	if authority == "" || value == "" {
+		writeJSONError(w, "Both authority and identifierValue query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	uuid, found, err := h.service.Lookup(authority, value)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if !found {
+		writeJSONError(w, fmt.Sprintf("No person with identifier %s from %s", value, authority), http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]string{"uuid": uuid}); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DuplicatesHandler reports pairs of people which are probably the same person, with a confidence of at least the
+// minConfidence query parameter
+func (h PeopleHandler) DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+
+	minConfidence := DefaultDuplicateConfidence
+	if v := r.URL.Query().Get("minConfidence"); v != "" {
+		var err error
+		if minConfidence, err = strconv.ParseFloat(v, 64); err != nil || minConfidence < 0 || minConfidence > 1 {
+			writeJSONError(w, "minConfidence must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+	}
+
+	duplicates, err := h.service.Duplicates(minConfidence)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(duplicates); err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type invalidRequestError interface {
+	InvalidRequestDetails() string
+}
+
+// statusClientClosedRequest is nginx's status for a client going away before it was answered, which no one will see,
+// but keeps the request from being logged as a failure of the service
+const statusClientClosedRequest = 499
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	if err == context.Canceled {
+		writeJSONError(w, "Request cancelled by the client", statusClientClosedRequest)
+		return
+	}
+	if err == context.DeadlineExceeded {
+		writeJSONError(w, "Timed out waiting for Neo4j", http.StatusGatewayTimeout)
+		return
+	}
+	if isTransient(err) {
+		// retrying has not helped yet, but the client trying again later may
+		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	switch e := err.(type) {
+	case circuitOpenError:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds()))))
+		writeJSONError(w, e.Error(), http.StatusServiceUnavailable)
+	case rwapi.ConstraintOrTransactionError:
+		writeJSONError(w, e.Error(), http.StatusConflict)
+	case invalidRequestError:
+		writeJSONError(w, e.InvalidRequestDetails(), http.StatusBadRequest)
+	default:
+		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// writeJSONError writes the error exactly as baseftrwapp did, so that clients of the read/write endpoints see no
+// change. The message is not escaped.
+func writeJSONError(w http.ResponseWriter, errorMsg string, statusCode int) {
+	w.WriteHeader(statusCode)
+	fmt.Fprintln(w, fmt.Sprintf("{\"message\": \"%s\"}", errorMsg))
+}
@@ -0,0 +1,180 @@
+package people
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/neo-utils-go/neoutils"
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/gorilla/mux"
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+)
+
+const handlerPersonUUID = "3fa70485-3a57-3b9b-9449-774b001cd965"
+
+// fakeConnection fills in the results of the queries it runs, in order, with its answers, which are JSON rows.
+// Queries run after the answers have run out get no rows.
+type fakeConnection struct {
+	answers *[]string
+	err     error
+}
+
+func answering(rows ...string) fakeConnection {
+	return fakeConnection{answers: &rows}
+}
+
+func (c fakeConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	if c.err != nil {
+		return c.err
+	}
+	for _, q := range queries {
+		if q.Result == nil || len(*c.answers) == 0 {
+			continue
+		}
+		if err := json.Unmarshal([]byte((*c.answers)[0]), q.Result); err != nil {
+			return err
+		}
+		*c.answers = (*c.answers)[1:]
+	}
+	return nil
+}
+
+func (c fakeConnection) EnsureConstraints(indexes map[string]string) error { return nil }
+func (c fakeConnection) EnsureIndexes(indexes map[string]string) error     { return nil }
+
+func serve(conn neoutils.NeoConnection, req *http.Request) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	NewPeopleHandler(NewCypherPeopleService(conn)).RegisterHandlers(router)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCountIsWrittenAsANumber(t *testing.T) {
+	w := serve(answering(`[{"c":3}]`), httptest.NewRequest("GET", "/people/__count", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "3\n", w.Body.String())
+}
+
+func TestCountFailureIsUnavailable(t *testing.T) {
+	w := serve(fakeConnection{err: errors.New("Neo4j is down")}, httptest.NewRequest("GET", "/people/__count", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "{\"message\": \"Neo4j is down\"}\n", w.Body.String())
+}
+
+func TestIDsAreStreamedOnePerLine(t *testing.T) {
+	w := serve(answering(`[{"id":"a","hash":"1"},{"id":"b","hash":"2"}]`), httptest.NewRequest("GET", "/people/__ids", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"id\":\"a\",\"hash\":\"1\"}\n{\"id\":\"b\",\"hash\":\"2\"}\n", w.Body.String())
+}
+
+func TestGetUnknownPersonIsNotFound(t *testing.T) {
+	w := serve(answering(), httptest.NewRequest("GET", "/people/"+handlerPersonUUID, nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestGetFailureIsUnavailable(t *testing.T) {
+	w := serve(fakeConnection{err: errors.New("Neo4j is down")}, httptest.NewRequest("GET", "/people/"+handlerPersonUUID, nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "{\"message\": \"Neo4j is down\"}\n", w.Body.String())
+}
+
+func handlerPerson(uuid string) string {
+	return `{"uuid":"` + uuid + `","prefLabel":"Shinzo Abe","alternativeIdentifiers":{"uuids":["` + uuid + `"]}}`
+}
+
+func TestPutWritesThePerson(t *testing.T) {
+	w := serve(answering(), httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, strings.NewReader(handlerPerson(handlerPersonUUID))))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestPutAcceptsGzippedBodies(t *testing.T) {
+	body := &bytes.Buffer{}
+	zw := gzip.NewWriter(body)
+	zw.Write([]byte(handlerPerson(handlerPersonUUID)))
+	zw.Close()
+
+	req := httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, body)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := serve(answering(), req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPutWithMismatchedUuidsIsBadRequest(t *testing.T) {
+	other := "026bc6ab-3581-476f-bdee-ad44934d8255"
+	w := serve(answering(), httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, strings.NewReader(handlerPerson(other))))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "{\"message\": \"Uuids from payload and request, respectively, do not match: '"+other+"' '"+handlerPersonUUID+"'\"}\n", w.Body.String())
+}
+
+func TestPutOfInvalidJSONIsBadRequest(t *testing.T) {
+	w := serve(answering(), httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, strings.NewReader("{")))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "{\"message\": \"unexpected EOF\"}\n", w.Body.String())
+}
+
+func TestPutConflictIsReported(t *testing.T) {
+	conn := fakeConnection{err: rwapi.ConstraintOrTransactionError{Message: "Duplicate identifier"}}
+	w := serve(conn, httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, strings.NewReader(handlerPerson(handlerPersonUUID))))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "{\"message\": \"Duplicate identifier\"}\n", w.Body.String())
+}
+
+func TestReconcileOnlyAcceptsPost(t *testing.T) {
+	w := serve(answering(), httptest.NewRequest("GET", "/people/__reconcile", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "{\"message\": \"Method GET is not allowed\"}\n", w.Body.String())
+}
+
+func TestPutOfAnotherPersonsMembershipIsAConflict(t *testing.T) {
+	membershipUUID := "5a3b0a4e-3f4f-4bb8-9d68-4b9b6bbbd5f0"
+	owner := "026bc6ab-3581-476f-bdee-ad44934d8255"
+	body := `{"uuid":"` + handlerPersonUUID + `","prefLabel":"Shinzo Abe","alternativeIdentifiers":{"uuids":["` + handlerPersonUUID + `"]},` +
+		`"memberships":[{"uuid":"` + membershipUUID + `","organisationUuid":"f1e6e9a8-0b7e-4d4b-9c8c-4e2cfb6e1d7a"}]}`
+	conn := answering(`[{"membership":"` + membershipUUID + `","owner":"` + owner + `"}]`)
+	w := serve(conn, httptest.NewRequest("PUT", "/people/"+handlerPersonUUID, strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "{\"message\": \"Membership "+membershipUUID+" belongs to person "+owner+", not "+handlerPersonUUID+"\"}\n", w.Body.String())
+}
+
+func TestImpactIsRemovableWhenTheCascadeRemovesEverything(t *testing.T) {
+	memberships := `[{"type":"HAS_MEMBER","direction":"incoming","kind":"membership","count":1},{"type":"HAS_IMAGE","direction":"outgoing","kind":"image","count":1}]`
+	for cascade, removable := range map[string]bool{"": false, "memberships": true} {
+		w := serve(answering(`[{"uuid":"`+handlerPersonUUID+`"}]`, memberships), httptest.NewRequest("GET", "/people/"+handlerPersonUUID+"/__impact?cascade="+cascade, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code, cascade)
+		i := impact{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&i))
+		assert.Equal(t, removable, i.Removable, cascade)
+	}
+}
+
+func TestImpactOfACascadeToAnnotationsIsBadRequest(t *testing.T) {
+	w := serve(answering(), httptest.NewRequest("GET", "/people/"+handlerPersonUUID+"/__impact?cascade=annotations", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,138 @@
+package people
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmcvetta/neoism"
+)
+
+var (
+	// as described by db.constraints() in Neo4j 3, e.g. CONSTRAINT ON ( person:Person ) ASSERT person.uuid IS UNIQUE
+	constraintDescriptionRegex = regexp.MustCompile(`^CONSTRAINT ON \( \w+:(\w+) \) ASSERT \w+\.(\w+) IS UNIQUE$`)
+	// as described by db.indexes(), e.g. INDEX ON :Identifier(value)
+	indexDescriptionRegex = regexp.MustCompile(`^INDEX ON :(\w+)\((\w+)\)$`)
+)
+
+// writeProbeLabel is the only label of the node the write probe writes to. It is neither a Thing nor an Identifier,
+// so verify, export and everything reading concepts pass it by, and is named for this service so that no other
+// service's node is written to.
+const writeProbeLabel = "PeopleRWNeo4jWriteProbe"
+
+// forHealthChecks is the service for the health checks, which should still find out whether Neo4j has recovered
+// while the circuit breaker is open, and not count towards it, nor towards the metrics for the work of the service
+func (s service) forHealthChecks() service {
+	s.breaker = nil
+	s.conn = uninstrumented(s.conn)
+	return s
+}
+
+// schema is the indexes and uniqueness constraints Initialise creates, as label to property
+func (s service) schema() (map[string]string, map[string]string) {
+	indexes := map[string]string{
+		"Identifier": "value",
+	}
+
+	constraints := s.authorities.constraints()
+	constraints["Thing"] = "uuid"
+	constraints["Concept"] = "uuid"
+	constraints["Person"] = "uuid"
+	constraints["Membership"] = "uuid"
+
+	return indexes, constraints
+}
+
+// CheckSchema fails if any index or constraint Initialise creates is missing from Neo4j, or an index is not online
+func (s service) CheckSchema() (string, error) {
+	constraints := []struct {
+		Description string `json:"description"`
+	}{}
+	indexes := []struct {
+		Description string `json:"description"`
+		State       string `json:"state"`
+	}{}
+
+	queries := []*neoism.CypherQuery{
+		{
+			Statement: `CALL db.constraints() YIELD description RETURN description`,
+			Result:    &constraints,
+		},
+		{
+			Statement: `CALL db.indexes() YIELD description, state RETURN description, state`,
+			Result:    &indexes,
+		},
+	}
+	if err := s.forHealthChecks().cypherBatch("", "health schema", queries); err != nil {
+		return "", err
+	}
+
+	found := make(map[string]bool)
+	for _, c := range constraints {
+		if m := constraintDescriptionRegex.FindStringSubmatch(c.Description); m != nil {
+			found["constraint "+m[1]+"."+m[2]] = true
+		}
+	}
+	for _, i := range indexes {
+		if m := indexDescriptionRegex.FindStringSubmatch(i.Description); m != nil && strings.EqualFold(i.State, "online") {
+			found["index "+m[1]+"."+m[2]] = true
+		}
+	}
+
+	expectedIndexes, expectedConstraints := s.schema()
+	var missing []string
+	for label, property := range expectedIndexes {
+		if !found["index "+label+"."+property] {
+			missing = append(missing, "index "+label+"."+property)
+		}
+	}
+	for label, property := range expectedConstraints {
+		if !found["constraint "+label+"."+property] {
+			missing = append(missing, "constraint "+label+"."+property)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("missing or offline: %s", strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("All %d indexes and %d constraints are online", len(expectedIndexes), len(expectedConstraints)), nil
+}
+
+// CheckPeopleLoaded fails if there are no people in Neo4j at all
+func (s service) CheckPeopleLoaded() (string, error) {
+	count, err := s.forHealthChecks().count(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", fmt.Errorf("there are no people in Neo4j")
+	}
+	return fmt.Sprintf("%d people loaded", count), nil
+}
+
+// CheckWriteLatency times a write to a node kept for the purpose, failing if it takes longer than threshold. The
+// node only has writeProbeLabel, so nothing else sees it. The write goes straight to Neo4j, so it is not counted in the
+// metrics, traces or circuit breaker for real writes, and gives up after the write timeout.
+func (s service) CheckWriteLatency(threshold time.Duration) (string, error) {
+	query := &neoism.CypherQuery{
+		Statement: `MERGE (h:` + writeProbeLabel + ` {id:"people-rw-neo4j"})
+					SET h.lastWritten = timestamp()`,
+	}
+
+	ctx, cancel := withTimeout(context.Background(), s.timeouts.Write)
+	defer cancel()
+	start := time.Now()
+	if err := runCypherBatch(ctx, uninstrumented(s.conn), []*neoism.CypherQuery{query}); err != nil {
+		return "", err
+	}
+	took := time.Since(start)
+
+	if took > threshold {
+		return "", fmt.Errorf("write took %s, more than %s", took, threshold)
+	}
+	return fmt.Sprintf("write took %s", took), nil
+}
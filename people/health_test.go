@@ -0,0 +1,48 @@
+package people
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSchemaDescriptionsAreParsed(t *testing.T) {
+	assert.Equal(t, []string{"CONSTRAINT ON ( person:Person ) ASSERT person.uuid IS UNIQUE", "Person", "uuid"},
+		constraintDescriptionRegex.FindStringSubmatch("CONSTRAINT ON ( person:Person ) ASSERT person.uuid IS UNIQUE"))
+	assert.Equal(t, []string{"INDEX ON :Identifier(value)", "Identifier", "value"},
+		indexDescriptionRegex.FindStringSubmatch("INDEX ON :Identifier(value)"))
+}
+
+func TestSchemaIncludesEveryAuthority(t *testing.T) {
+	indexes, constraints := NewCypherPeopleService(stubConnection{}).schema()
+	assert.Equal(t, map[string]string{"Identifier": "value"}, indexes)
+	for _, a := range DefaultAuthorities() {
+		assert.Equal(t, "value", constraints[a.Label], a.Label)
+	}
+	for _, label := range []string{"Thing", "Concept", "Person", "Membership"} {
+		assert.Equal(t, "uuid", constraints[label], label)
+	}
+}
+
+func TestWriteProbeBypassesTheBreakerAndTracing(t *testing.T) {
+	withRecordedSpans(t, func(recorder *tracetest.SpanRecorder) {
+		s := NewCypherPeopleService(stubConnection{})
+		s.breaker, _ = testBreaker()
+		s.breaker.trip(s.breaker.now())
+
+		_, err := s.CheckWriteLatency(time.Minute)
+		assert.NoError(t, err, "the probe should reach Neo4j while the breaker is open")
+		assert.Empty(t, recorder.Ended())
+		assert.Equal(t, breakerOpen, s.breaker.state)
+	})
+}
+
+func TestWriteProbeTimesOut(t *testing.T) {
+	s := NewCypherPeopleService(hungConnection{started: make(chan struct{})}).WithTimeouts(Timeouts{Write: 10 * time.Millisecond})
+
+	_, err := s.CheckWriteLatency(time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
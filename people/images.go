@@ -0,0 +1,137 @@
+package people
+
+import (
+	"fmt"
+
+	"github.com/jmcvetta/neoism"
+)
+
+const headshotRole = "headshot"
+
+// primaryImageURL is the url of the first variant of the first headshot, which is what _imageUrl used to hold
+func primaryImageURL(images []image) string {
+	for _, i := range images {
+		if i.Role == headshotRole && len(i.Variants) > 0 {
+			return i.Variants[0].URL
+		}
+	}
+	return ""
+}
+
+// validateImages also rejects an _imageUrl which disagrees with images, as it is derived from them and would otherwise
+// be silently dropped
+func validateImages(images []image, imageURL string) error {
+	for _, i := range images {
+		if i.UUID == "" {
+			return fmt.Errorf("image without a uuid")
+		}
+		if i.Role == "" {
+			return fmt.Errorf("image %s has no role", i.UUID)
+		}
+		for _, v := range i.Variants {
+			if v.URL == "" {
+				return fmt.Errorf("variant of image %s has no url", i.UUID)
+			}
+		}
+	}
+	if len(images) > 0 && imageURL != "" && imageURL != primaryImageURL(images) {
+		return fmt.Errorf("_imageUrl %s is not the first variant of the first headshot in images, leave it out when giving images", imageURL)
+	}
+	return nil
+}
+
+// storedImage is an image as read from Neo4j, where the variants are held as parallel lists on the HAS_IMAGE
+// relationship
+type storedImage struct {
+	UUID           string   `json:"uuid"`
+	Role           string   `json:"role"`
+	VariantURLs    []string `json:"variantUrls"`
+	VariantCrops   []string `json:"variantCrops"`
+	VariantWidths  []int    `json:"variantWidths"`
+	VariantHeights []int    `json:"variantHeights"`
+}
+
+func (si storedImage) image() image {
+	i := image{UUID: si.UUID, Role: si.Role}
+	for n, url := range si.VariantURLs {
+		v := imageVariant{URL: url}
+		if n < len(si.VariantCrops) {
+			v.Crop = si.VariantCrops[n]
+		}
+		if n < len(si.VariantWidths) {
+			v.Width = si.VariantWidths[n]
+		}
+		if n < len(si.VariantHeights) {
+			v.Height = si.VariantHeights[n]
+		}
+		i.Variants = append(i.Variants, v)
+	}
+	return i
+}
+
+func readImagesQuery(uuid string, results *[]storedImage) *neoism.CypherQuery {
+	return &neoism.CypherQuery{
+		Statement: `MATCH (:Person {uuid:{uuid}})-[r:HAS_IMAGE]->(i:Thing)
+					RETURN i.uuid as uuid,
+						r.role as role,
+						r.variantUrls as variantUrls,
+						r.variantCrops as variantCrops,
+						r.variantWidths as variantWidths,
+						r.variantHeights as variantHeights
+					ORDER BY r.position`,
+		Parameters: map[string]interface{}{
+			"uuid": uuid,
+		},
+		Result: results,
+	}
+}
+
+// deleteImagesQuery removes the person's images, along with the Things for them which nothing else refers to or has
+// given a label, so that replacing or deleting images leaves nothing behind
+func deleteImagesQuery(uuid string) *neoism.CypherQuery {
+	return &neoism.CypherQuery{
+		Statement: `MATCH (:Thing {uuid:{uuid}})-[r:HAS_IMAGE]->(i:Thing)
+				OPTIONAL MATCH (i)-[other]-(x)
+				WHERE NOT (type(other) = 'HAS_IMAGE' AND x.uuid = {uuid})
+				WITH i, collect(DISTINCT r) as images, count(other) as others
+				FOREACH (r IN images | DELETE r)
+				WITH i, others
+				WHERE others = 0 AND size(labels(i)) = 1
+				DELETE i`,
+		Parameters: map[string]interface{}{
+			"uuid": uuid,
+		},
+	}
+}
+
+func createImageQuery(uuid string, position int, i image) *neoism.CypherQuery {
+	// empty crops and sizes are stored so the lists stay parallel
+	urls := []string{}
+	crops := []string{}
+	widths := []int{}
+	heights := []int{}
+	for _, v := range i.Variants {
+		urls = append(urls, v.URL)
+		crops = append(crops, v.Crop)
+		widths = append(widths, v.Width)
+		heights = append(heights, v.Height)
+	}
+
+	return &neoism.CypherQuery{
+		Statement: `MERGE (p:Thing {uuid:{uuid}})
+					MERGE (i:Thing {uuid:{imageUuid}})
+					CREATE (p)-[:HAS_IMAGE {props}]->(i)`,
+		Parameters: map[string]interface{}{
+			"uuid":      uuid,
+			"imageUuid": i.UUID,
+			"props": map[string]interface{}{
+				"role":           i.Role,
+				"position":       position,
+				"variantUrls":    urls,
+				"variantCrops":   crops,
+				"variantWidths":  widths,
+				"variantHeights": heights,
+			},
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryImageURLIsFirstHeadshotVariant(t *testing.T) {
+	images := []image{
+		{UUID: "1", Role: "byline", Variants: []imageVariant{{URL: "http://byline"}}},
+		{UUID: "2", Role: headshotRole},
+		{UUID: "3", Role: headshotRole, Variants: []imageVariant{{URL: "http://square"}, {URL: "http://wide"}}},
+	}
+	assert.Equal(t, "http://square", primaryImageURL(images))
+	assert.Equal(t, "", primaryImageURL(images[:2]))
+}
+
+func TestStoredImageKeepsVariantsInOrder(t *testing.T) {
+	stored := storedImage{
+		UUID:           "1",
+		Role:           headshotRole,
+		VariantURLs:    []string{"http://square", "http://wide"},
+		VariantCrops:   []string{"square", ""},
+		VariantWidths:  []int{150, 0},
+		VariantHeights: []int{150, 0},
+	}
+	assert.Equal(t, image{UUID: "1", Role: headshotRole, Variants: []imageVariant{
+		{URL: "http://square", Crop: "square", Width: 150, Height: 150},
+		{URL: "http://wide"},
+	}}, stored.image())
+}
+
+func TestValidateImages(t *testing.T) {
+	assert.NoError(t, validateImages([]image{{UUID: "1", Role: headshotRole}}, ""))
+	assert.Error(t, validateImages([]image{{Role: headshotRole}}, ""))
+	assert.Error(t, validateImages([]image{{UUID: "1"}}, ""))
+	assert.Error(t, validateImages([]image{{UUID: "1", Role: headshotRole, Variants: []imageVariant{{Crop: "square"}}}}, ""))
+}
+
+func TestImageURLMustAgreeWithImages(t *testing.T) {
+	images := []image{{UUID: "1", Role: headshotRole, Variants: []imageVariant{{URL: "http://square"}}}}
+
+	assert.NoError(t, validateImages(nil, "http://old"))
+	assert.NoError(t, validateImages(images, "http://square"))
+	assert.Error(t, validateImages(images, "http://old"))
+	assert.Error(t, validateImages([]image{{UUID: "1", Role: "byline"}}, "http://old"))
+}
@@ -0,0 +1,126 @@
+package people
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmcvetta/neoism"
+)
+
+const (
+	annotationKind = "annotation"
+	authorshipKind = "authorship"
+	membershipKind = "membership"
+	imageKind      = "image"
+	otherKind      = "other"
+)
+
+// cascadeMemberships is the only thing a delete can take with it. Annotations belong to the content, so are never
+// removed.
+const cascadeMemberships = "memberships"
+
+// impact describes what refers to a person, and so what a delete would affect
+type impact struct {
+	UUID          string              `json:"uuid"`
+	Relationships []relationshipCount `json:"relationships"`
+	// Removable is whether a delete with the same cascade would remove the node entirely, rather than leave a bare
+	// Thing behind for the relationships which are kept
+	Removable bool `json:"removable"`
+}
+
+// relationshipCount is how many relationships of a type a person has with other Things in one direction
+type relationshipCount struct {
+	Type      string `json:"type"`
+	Direction string `json:"direction"`
+	Kind      string `json:"kind"`
+	Count     int    `json:"count"`
+}
+
+// Impact lists the relationships between the person and other Things, by type, direction and what they represent.
+// cascade is what a delete would also remove, as for DeleteWithCascade.
+func (s service) Impact(uuid string, cascade string) (impact, bool, error) {
+	options, err := parseCascade(cascade)
+	if err != nil {
+		return impact{}, false, requestError{err.Error()}
+	}
+	removed := map[string]bool{
+		// images are always removed with the person
+		imageKind: true,
+	}
+	for _, option := range options {
+		if option == cascadeMemberships {
+			removed[membershipKind] = true
+		}
+	}
+
+	people := []struct {
+		UUID string `json:"uuid"`
+	}{}
+	existsQuery := &neoism.CypherQuery{
+		Statement:  `MATCH (p:Person {uuid:{uuid}}) RETURN p.uuid as uuid`,
+		Parameters: map[string]interface{}{"uuid": uuid},
+		Result:     &people,
+	}
+
+	relationships := []relationshipCount{}
+	relationshipsQuery := &neoism.CypherQuery{
+		Statement: `MATCH (p:Person {uuid:{uuid}})-[r]-(x:Thing)
+					RETURN type(r) as type,
+						CASE WHEN startNode(r) = p THEN "outgoing" ELSE "incoming" END as direction,
+						CASE
+							WHEN x:Content AND type(r) = "HAS_AUTHOR" THEN "` + authorshipKind + `"
+							WHEN x:Content THEN "` + annotationKind + `"
+							WHEN x:Membership AND type(r) = "HAS_MEMBER" THEN "` + membershipKind + `"
+							WHEN type(r) = "HAS_IMAGE" THEN "` + imageKind + `"
+							ELSE "` + otherKind + `"
+						END as kind,
+						count(r) as count
+					ORDER BY kind, type, direction`,
+		Parameters: map[string]interface{}{"uuid": uuid},
+		Result:     &relationships,
+	}
+
+	if err := s.cypherBatch("", "impact", []*neoism.CypherQuery{existsQuery, relationshipsQuery}); err != nil {
+		return impact{}, false, err
+	}
+	if len(people) == 0 {
+		return impact{}, false, nil
+	}
+
+	i := impact{UUID: uuid, Relationships: relationships, Removable: true}
+	for _, r := range relationships {
+		if !removed[r.Kind] {
+			i.Removable = false
+		}
+	}
+	return i, true, nil
+}
+
+// parseCascade checks the comma separated list of what a delete should also remove
+func parseCascade(cascade string) ([]string, error) {
+	var options []string
+	for _, option := range strings.Split(cascade, ",") {
+		option = strings.TrimSpace(option)
+		switch option {
+		case "":
+			continue
+		case cascadeMemberships:
+			options = append(options, option)
+		case "annotations":
+			return nil, fmt.Errorf("annotations belong to content and cannot be removed by deleting a person")
+		default:
+			return nil, fmt.Errorf("cannot cascade a delete to %q, only to %s", option, cascadeMemberships)
+		}
+	}
+	return options, nil
+}
+
+func deleteMembershipsQuery(uuid string) *neoism.CypherQuery {
+	return &neoism.CypherQuery{
+		Statement: `MATCH (m:Membership)-[:HAS_MEMBER]->(:Thing {uuid:{uuid}})
+				DETACH DELETE m`,
+		Parameters: map[string]interface{}{
+			"uuid": uuid,
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCascade(t *testing.T) {
+	options, err := parseCascade("")
+	assert.NoError(t, err)
+	assert.Empty(t, options)
+
+	options, err = parseCascade(" memberships ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{cascadeMemberships}, options)
+
+	_, err = parseCascade("memberships,annotations")
+	assert.Error(t, err)
+
+	_, err = parseCascade("everything")
+	assert.Error(t, err)
+}
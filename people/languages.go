@@ -0,0 +1,155 @@
+package people
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// languageTagRegex matches BCP 47 style language tags such as en, ja or zh-Hans
+var languageTagRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+func validateLanguageTags(p person) error {
+	for lang := range p.PrefLabels {
+		if !languageTagRegex.MatchString(lang) {
+			return fmt.Errorf("%q in prefLabels is not a language tag", lang)
+		}
+	}
+	for lang := range p.LanguageAliases {
+		if !languageTagRegex.MatchString(lang) {
+			return fmt.Errorf("%q in languageAliases is not a language tag", lang)
+		}
+	}
+	return nil
+}
+
+// flattenLabels turns language tagged labels into two parallel lists, as Neo4j cannot store maps on a node
+func flattenLabels(labels map[string]string) (languages []string, values []string) {
+	for _, lang := range sortedKeys(labels) {
+		languages = append(languages, lang)
+		values = append(values, labels[lang])
+	}
+	return languages, values
+}
+
+// flattenAliases turns language tagged aliases into two parallel lists, repeating the language for each alias
+func flattenAliases(aliases map[string][]string) (languages []string, values []string) {
+	langs := make([]string, 0, len(aliases))
+	for lang := range aliases {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		for _, alias := range aliases[lang] {
+			languages = append(languages, lang)
+			values = append(values, alias)
+		}
+	}
+	return languages, values
+}
+
+func unflattenLabels(languages []string, values []string) map[string]string {
+	if len(languages) == 0 || len(languages) != len(values) {
+		return nil
+	}
+	labels := make(map[string]string, len(languages))
+	for i, lang := range languages {
+		labels[lang] = values[i]
+	}
+	return labels
+}
+
+func unflattenAliases(languages []string, values []string) map[string][]string {
+	if len(languages) == 0 || len(languages) != len(values) {
+		return nil
+	}
+	aliases := make(map[string][]string)
+	for i, lang := range languages {
+		aliases[lang] = append(aliases[lang], values[i])
+	}
+	return aliases
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// negotiateLanguage picks the best of the available language tags for an Accept-Language header, or "" if none
+// is acceptable. A requested tag matches an available one exactly or as a prefix in either direction, so "zh"
+// matches "zh-Hans" and "en-GB" matches "en".
+func negotiateLanguage(acceptLanguage string, available []string) string {
+	var requested byQuality
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			requested = append(requested, weightedLanguage{tag, q})
+		}
+	}
+
+	// stable, so tags of equal weight keep the client's order
+	sort.Stable(requested)
+
+	for _, r := range requested {
+		if r.tag == "*" && len(available) > 0 {
+			return available[0]
+		}
+		for _, tag := range available {
+			if strings.EqualFold(tag, r.tag) {
+				return tag
+			}
+		}
+		for _, tag := range available {
+			if languagePrefixOf(r.tag, tag) || languagePrefixOf(tag, r.tag) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+type weightedLanguage struct {
+	tag string
+	q   float64
+}
+
+type byQuality []weightedLanguage
+
+func (b byQuality) Len() int           { return len(b) }
+func (b byQuality) Less(i, j int) bool { return b[i].q > b[j].q }
+func (b byQuality) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func languagePrefixOf(prefix string, tag string) bool {
+	return len(tag) > len(prefix) && strings.EqualFold(tag[:len(prefix)], prefix) && tag[len(prefix)] == '-'
+}
+
+// localised returns the person with prefLabel replaced by the label in the language best matching acceptLanguage,
+// for clients which only understand a single prefLabel, along with the language chosen
+func (p person) localised(acceptLanguage string) (person, string) {
+	lang := negotiateLanguage(acceptLanguage, sortedKeys(p.PrefLabels))
+	if lang == "" {
+		return p, ""
+	}
+	p.PrefLabel = p.PrefLabels[lang]
+	return p, lang
+}
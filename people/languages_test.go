@@ -0,0 +1,38 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	available := []string{"en", "ja", "zh-Hans"}
+
+	for acceptLanguage, expected := range map[string]string{
+		"ja":                     "ja",
+		"JA":                     "ja",
+		"fr, ja;q=0.5, en;q=0.8": "en",
+		"zh":                     "zh-Hans",
+		"zh-Hans-CN":             "zh-Hans",
+		"en-GB,en;q=0.9":         "en",
+		"fr":                     "",
+		"*":                      "en",
+		"ja;q=0, en;q=0.1":       "en",
+		"de;q=0.9, ja;q=0.9, en": "en",
+		"de;q=0.9, ja;q=0.9, fr": "ja",
+	} {
+		assert.Equal(t, expected, negotiateLanguage(acceptLanguage, available), "Wrong language for %q", acceptLanguage)
+	}
+}
+
+func TestLanguageAliasesSurviveFlattening(t *testing.T) {
+	aliases := map[string][]string{
+		"ja": {"ロバート・アディントン"},
+		"en": {"Bob Addington", "R. Addington"},
+	}
+
+	languages, values := flattenAliases(aliases)
+	assert.Equal(t, []string{"en", "en", "ja"}, languages)
+	assert.Equal(t, aliases, unflattenAliases(languages, values))
+}
@@ -0,0 +1,315 @@
+package people
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriteLimits stop one client's writes and deletes, such as a bulk load, from starving everyone else's. Clients are
+// told apart by the ClientHeader request header. A write waits up to MaxWait for its turn before being refused with
+// 429 Too Many Requests. A limit of 0 turns it off.
+//
+// The limits are advisory: the header is whatever the client says it is, so they keep well behaved clients from
+// crowding each other out rather than defend against a hostile one. Only the overall limits hold for every request.
+// When Clients is given, only those ids have limits of their own. Requests without the header, with an id which is
+// not in Clients, or from a new client while as many as can be are being tracked, share a pool with the per client
+// limits, so none of them escape the per client limits by changing or leaving out their id.
+type WriteLimits struct {
+	MaxConcurrent          int
+	MaxConcurrentPerClient int
+	PerSecond              float64
+	PerSecondPerClient     float64
+	MaxWait                time.Duration
+	ClientHeader           string
+	Clients                []string
+}
+
+// DefaultWriteLimits leave room for other clients while one is writing as fast as it can, without limiting the rate
+func DefaultWriteLimits() WriteLimits {
+	return WriteLimits{
+		MaxConcurrent:          32,
+		MaxConcurrentPerClient: 16,
+		MaxWait:                time.Second,
+		ClientHeader:           "X-Origin-System-Id",
+	}
+}
+
+// WithWriteLimits returns the handler limiting writes and deletes by limits instead of the defaults
+func (h PeopleHandler) WithWriteLimits(limits WriteLimits) PeopleHandler {
+	h.limiter = newWriteLimiter(limits)
+	return h
+}
+
+// past this many clients, those with no requests using their limits and a full bucket are forgotten. No more are
+// ever tracked, new clients sharing the pool instead.
+const maxTrackedClients = 1000
+
+// maxClientIDLength is the longest client id given limits of its own
+const maxClientIDLength = 256
+
+const (
+	concurrencyRejection = "concurrency"
+	rateRejection        = "rate"
+)
+
+type writeLimiter struct {
+	sync.Mutex
+	limits  WriteLimits
+	now     func() time.Time
+	slots   chan struct{}
+	rate    *tokenBucket
+	clients map[string]*clientLimits
+	// known are the only client ids given limits of their own, or nil for any
+	known map[string]bool
+	// shared are the limits of requests which are not given limits of their own
+	shared *clientLimits
+}
+
+type clientLimits struct {
+	slots chan struct{}
+	rate  *tokenBucket
+	// requests given these limits by client and not yet done with them, guarded by the limiter's lock
+	users int
+}
+
+func newWriteLimiter(limits WriteLimits) *writeLimiter {
+	l := &writeLimiter{
+		limits:  limits,
+		now:     time.Now,
+		slots:   newSlots(limits.MaxConcurrent),
+		rate:    newTokenBucket(limits.PerSecond),
+		clients: make(map[string]*clientLimits),
+		shared:  newClientLimits(limits),
+	}
+	if len(limits.Clients) > 0 {
+		l.known = make(map[string]bool)
+		for _, id := range limits.Clients {
+			l.known[clientKey(id)] = true
+		}
+	}
+	return l
+}
+
+func newClientLimits(limits WriteLimits) *clientLimits {
+	return &clientLimits{slots: newSlots(limits.MaxConcurrentPerClient), rate: newTokenBucket(limits.PerSecondPerClient)}
+}
+
+// clientKey is the id a client's limits are kept under, so ids differing only in case or spacing share them
+func clientKey(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// limitWrites wraps a write or delete handler, queueing the request until the limits allow it
+func (h PeopleHandler) limitWrites(handler http.HandlerFunc) http.HandlerFunc {
+	if h.limiter == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, reason, retryAfter := h.limiter.acquire(r)
+		if release == nil {
+			writeLimitsRejections.WithLabelValues(reason).Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeJSONError(w, "Too many writes, try again later", http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		handler(w, r)
+	}
+}
+
+// acquire waits for the request's turn, returning a function to call once it has finished. If it cannot have a turn
+// within MaxWait, it gets the reason and how long it should wait before trying again instead.
+func (l *writeLimiter) acquire(r *http.Request) (func(), string, time.Duration) {
+	client := l.client(r.Header.Get(l.limits.ClientHeader))
+	giveUp := time.NewTimer(l.limits.MaxWait)
+	defer giveUp.Stop()
+
+	wait, ok := l.reserve(client)
+	if !ok {
+		l.done(client)
+		return nil, rateRejection, wait
+	}
+	// the write will not happen after this, so does not count against the rate
+	reject := func(reason string, retryAfter time.Duration) (func(), string, time.Duration) {
+		l.giveBack(client)
+		l.done(client)
+		return nil, reason, retryAfter
+	}
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return reject(rateRejection, wait)
+		}
+	}
+
+	if !takeSlot(client.slots, giveUp.C, r) {
+		return reject(concurrencyRejection, time.Second)
+	}
+	if !takeSlot(l.slots, giveUp.C, r) {
+		releaseSlot(client.slots)
+		return reject(concurrencyRejection, time.Second)
+	}
+
+	return func() {
+		releaseSlot(l.slots)
+		releaseSlot(client.slots)
+		l.done(client)
+	}, "", 0
+}
+
+// client is the limits for the client id, which are the shared ones if it does not have its own
+func (l *writeLimiter) client(id string) *clientLimits {
+	l.Lock()
+	defer l.Unlock()
+
+	id = clientKey(id)
+	if id == "" || len(id) > maxClientIDLength || (l.known != nil && !l.known[id]) {
+		l.shared.users++
+		return l.shared
+	}
+	if c, found := l.clients[id]; found {
+		c.users++
+		return c
+	}
+	if len(l.clients) >= maxTrackedClients {
+		now := l.now()
+		for other, c := range l.clients {
+			if c.users == 0 && c.rate.full(now) {
+				delete(l.clients, other)
+			}
+		}
+	}
+	if len(l.clients) >= maxTrackedClients {
+		l.shared.users++
+		return l.shared
+	}
+	c := newClientLimits(l.limits)
+	c.users = 1
+	l.clients[id] = c
+	return c
+}
+
+// done says a request given the client's limits has finished with them, so they can be forgotten once no one else
+// is using them
+func (l *writeLimiter) done(client *clientLimits) {
+	l.Lock()
+	defer l.Unlock()
+	client.users--
+}
+
+// giveBack returns the tokens reserve took for a write which did not happen
+func (l *writeLimiter) giveBack(client *clientLimits) {
+	l.Lock()
+	defer l.Unlock()
+	client.rate.giveBack()
+	l.rate.giveBack()
+}
+
+// reserve takes a token from both the client's and the global bucket, returning how long to wait before using them.
+// Nothing is taken when that would be longer than MaxWait.
+func (l *writeLimiter) reserve(client *clientLimits) (time.Duration, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	clientWait := client.rate.take(now)
+	globalWait := l.rate.take(now)
+	wait := clientWait
+	if globalWait > wait {
+		wait = globalWait
+	}
+	if wait > l.limits.MaxWait {
+		client.rate.giveBack()
+		l.rate.giveBack()
+		return wait, false
+	}
+	return wait, true
+}
+
+// newSlots is a semaphore of size n, or nil for no limit
+func newSlots(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+func takeSlot(slots chan struct{}, giveUp <-chan time.Time, r *http.Request) bool {
+	if slots == nil {
+		return true
+	}
+	// a free slot is taken even if there is no time left to wait for one
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-giveUp:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func releaseSlot(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
+
+// tokenBucket allows rate writes a second, and bursts of up to a second's worth. A nil bucket has no limit.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, tokens: math.Max(rate, 1)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(math.Max(b.rate, 1), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// take removes a token, returning how long until it would have been there
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) giveBack() {
+	if b != nil {
+		b.tokens++
+	}
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.refill(now)
+	return b.tokens >= math.Max(b.rate, 1)
+}
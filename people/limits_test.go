@@ -0,0 +1,141 @@
+package people
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketWaitsForTheRate(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := newTokenBucket(2)
+
+	assert.Equal(t, time.Duration(0), b.take(now))
+	assert.Equal(t, time.Duration(0), b.take(now))
+	assert.Equal(t, 500*time.Millisecond, b.take(now))
+	b.giveBack()
+
+	assert.Equal(t, time.Duration(0), b.take(now.Add(time.Second)))
+	assert.True(t, b.full(now.Add(time.Minute)))
+}
+
+func limitedRequest(h http.HandlerFunc, client string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/people/180cec41-23fa-4148-806b-0602924e6858", nil)
+	req.Header.Set("X-Origin-System-Id", client)
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+func TestConcurrentWritesPerClientAreCapped(t *testing.T) {
+	h := PeopleHandler{}.WithWriteLimits(WriteLimits{MaxConcurrentPerClient: 1, MaxWait: 10 * time.Millisecond, ClientHeader: "X-Origin-System-Id"})
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	slow := h.limitWrites(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	})
+	done := make(chan struct{})
+	go func() {
+		limitedRequest(slow, "bulk-loader")
+		close(done)
+	}()
+	<-started
+
+	fast := h.limitWrites(func(w http.ResponseWriter, r *http.Request) {})
+	rejected := limitedRequest(fast, "bulk-loader")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "1", rejected.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, limitedRequest(fast, "editorial").Code, "other clients still get through")
+
+	close(finish)
+	<-done
+	assert.Equal(t, http.StatusOK, limitedRequest(fast, "bulk-loader").Code)
+}
+
+func TestWritesOverTheRateAreQueuedThenRefused(t *testing.T) {
+	h := PeopleHandler{}.WithWriteLimits(WriteLimits{PerSecond: 20, MaxWait: 60 * time.Millisecond})
+	handler := h.limitWrites(func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, http.StatusOK, limitedRequest(handler, "").Code, "the first second's worth is a burst")
+	}
+
+	// the next token is 50ms away and the one after that 100ms, which is longer than the writes can wait
+	start := time.Now()
+	results := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- limitedRequest(handler, "") }()
+	}
+	codes := map[int]int{}
+	for i := 0; i < 2; i++ {
+		w := <-results
+		codes[w.Code]++
+		if w.Code == http.StatusTooManyRequests {
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		}
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusTooManyRequests: 1}, codes)
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "queued until there is a token")
+}
+
+func TestClientsInUseAreNotForgotten(t *testing.T) {
+	l := newWriteLimiter(WriteLimits{MaxConcurrentPerClient: 1})
+	busy := l.client("busy")
+	for i := 0; len(l.clients) < maxTrackedClients; i++ {
+		l.done(l.client(strconv.Itoa(i)))
+	}
+
+	l.done(l.client("another"))
+	assert.True(t, busy == l.clients["busy"], "a client handed out but not yet done should keep its limits")
+	assert.True(t, len(l.clients) < maxTrackedClients, "idle clients should be forgotten")
+}
+
+func TestCancelledWritesGiveBackTheirTokens(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := newWriteLimiter(WriteLimits{PerSecond: 1, MaxWait: time.Minute})
+	l.now = func() time.Time { return now }
+
+	req := httptest.NewRequest("PUT", "/people/180cec41-23fa-4148-806b-0602924e6858", nil)
+	release, _, _ := l.acquire(req)
+	assert.NotNil(t, release, "the first second's worth is a burst")
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release, reason, _ := l.acquire(req.WithContext(ctx))
+	assert.Nil(t, release)
+	assert.Equal(t, rateRejection, reason)
+
+	wait, ok := l.reserve(l.client(""))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, wait, "the cancelled write should not have used up the next token")
+}
+
+func TestClientsAreNeverTrackedPastTheLimit(t *testing.T) {
+	l := newWriteLimiter(WriteLimits{MaxConcurrentPerClient: 1})
+	for i := 0; i < maxTrackedClients; i++ {
+		l.client(strconv.Itoa(i))
+	}
+
+	assert.True(t, l.shared == l.client("another"), "a new client should share the pool while every tracked one is busy")
+	assert.Equal(t, maxTrackedClients, len(l.clients))
+}
+
+func TestRequestsWithoutTheirOwnLimitsShareAPool(t *testing.T) {
+	l := newWriteLimiter(WriteLimits{MaxConcurrentPerClient: 1, Clients: []string{"http://cmdb.ft.com/systems/upp"}})
+
+	assert.True(t, l.shared == l.client(""), "requests without the header")
+	assert.True(t, l.shared == l.client("rotated-1"), "clients which are not known")
+	assert.True(t, l.shared == l.client("rotated-2"))
+	upp := l.client("http://cmdb.ft.com/systems/upp")
+	assert.False(t, l.shared == upp)
+	assert.True(t, upp == l.client(" HTTP://CMDB.FT.COM/SYSTEMS/UPP"), "ids differing in case and spacing are the same client")
+	assert.Equal(t, 1, len(l.clients))
+}
@@ -0,0 +1,135 @@
+package people
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/neo-utils-go/neoutils"
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/jmcvetta/neoism"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The metrics below are exposed in the Prometheus text format by MetricsHandler. They are always recorded, whether
+// or not the endpoint is served.
+
+const (
+	writtenOutcome   = "written"
+	rewrittenOutcome = "rewritten"
+	conflictOutcome  = "conflict"
+	invalidOutcome   = "invalid"
+	errorOutcome     = "error"
+)
+
+var (
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "people_rw_neo4j_operation_duration_seconds",
+		Help:    "Time taken by each operation on people, in seconds",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+	cypherBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "people_rw_neo4j_cypher_batch_statements",
+		Help:    "Number of statements in each Cypher batch sent to Neo4j",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+	writeOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_writes_total",
+		Help: "Writes of people by outcome: written, rewritten (same hash as before, written again anyway), conflict, invalid or error",
+	}, []string{"outcome"})
+	neo4jErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_neo4j_errors_total",
+		Help: "Errors returned by Neo4j, by whether they were constraint or transaction errors",
+	}, []string{"type"})
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_retries_total",
+		Help: "Writes and deletes run again after a transient Neo4j error, by operation",
+	}, []string{"operation"})
+	retriesExhausted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_retries_exhausted_total",
+		Help: "Writes and deletes which still failed with a transient Neo4j error after every attempt, by operation",
+	}, []string{"operation"})
+	breakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_circuit_breaker_transitions_total",
+		Help: "Changes of the circuit breaker around Neo4j to each state: open, half_open or closed",
+	}, []string{"state"})
+	writeLimitsRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_write_limit_rejections_total",
+		Help: "Writes and deletes refused with 429 by the limit they hit: concurrency or rate",
+	}, []string{"reason"})
+	sweptIdentifiers = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_identifier_sweeper_removed_total",
+		Help: "Orphaned Identifier nodes removed by the identifier sweeper",
+	})
+	lastSweptIdentifiers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "people_rw_neo4j_identifier_sweeper_last_removed",
+		Help: "Orphaned Identifier nodes removed by the last identifier sweep",
+	})
+	sweepFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "people_rw_neo4j_identifier_sweeper_failures_total",
+		Help: "Identifier sweeps which failed part way through",
+	})
+)
+
+// metricsRegistry holds just the people metrics, rather than everything registered by the libraries in use
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(operationDuration, cypherBatchSize, writeOutcomes, neo4jErrors, retries, retriesExhausted,
+		breakerTransitions, writeLimitsRejections, sweptIdentifiers, lastSweptIdentifiers, sweepFailures)
+}
+
+var metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+
+// MetricsHandler serves the metrics in the Prometheus text exposition format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
+
+// observeOperation records how long an operation took, and is meant to be deferred at its start
+func observeOperation(operation string, start time.Time) {
+	operationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func recordWriteOutcome(sameHash bool, err error) {
+	switch err.(type) {
+	case nil:
+		if sameHash {
+			writeOutcomes.WithLabelValues(rewrittenOutcome).Inc()
+		} else {
+			writeOutcomes.WithLabelValues(writtenOutcome).Inc()
+		}
+	case requestError:
+		writeOutcomes.WithLabelValues(invalidOutcome).Inc()
+	case rwapi.ConstraintOrTransactionError:
+		writeOutcomes.WithLabelValues(conflictOutcome).Inc()
+	default:
+		writeOutcomes.WithLabelValues(errorOutcome).Inc()
+	}
+}
+
+// instrumentedConnection records the size of every batch and the errors Neo4j returns
+type instrumentedConnection struct {
+	neoutils.NeoConnection
+}
+
+func (c instrumentedConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	cypherBatchSize.Observe(float64(len(queries)))
+	err := c.NeoConnection.CypherBatch(queries)
+	switch err.(type) {
+	case nil:
+	case rwapi.ConstraintOrTransactionError:
+		neo4jErrors.WithLabelValues("constraint").Inc()
+	default:
+		neo4jErrors.WithLabelValues("other").Inc()
+	}
+	return err
+}
+
+// uninstrumented is the connection under the instrumentation, for queries which are not part of the service's work
+func uninstrumented(conn neoutils.NeoConnection) neoutils.NeoConnection {
+	if c, ok := conn.(instrumentedConnection); ok {
+		return c.NeoConnection
+	}
+	return conn
+}
@@ -0,0 +1,94 @@
+package people
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationDurationsAreServedAsAHistogram(t *testing.T) {
+	operationDuration.WithLabelValues("test").Observe(0.05)
+	operationDuration.WithLabelValues("test").Observe(5)
+
+	w := httptest.NewRecorder()
+	MetricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `people_rw_neo4j_operation_duration_seconds_bucket{operation="test",le="0.05"} 1`)
+	assert.Contains(t, w.Body.String(), `people_rw_neo4j_operation_duration_seconds_bucket{operation="test",le="+Inf"} 2`)
+	assert.Contains(t, w.Body.String(), `people_rw_neo4j_operation_duration_seconds_count{operation="test"} 2`)
+}
+
+func TestWriteOutcomesAreCounted(t *testing.T) {
+	outcomes := []string{writtenOutcome, rewrittenOutcome, invalidOutcome, conflictOutcome, errorOutcome}
+	before := map[string]float64{}
+	for _, outcome := range outcomes {
+		before[outcome] = testutil.ToFloat64(writeOutcomes.WithLabelValues(outcome))
+	}
+
+	recordWriteOutcome(false, nil)
+	recordWriteOutcome(true, nil)
+	recordWriteOutcome(false, requestError{"bad"})
+	recordWriteOutcome(false, rwapi.ConstraintOrTransactionError{Message: "clash"})
+	recordWriteOutcome(false, errors.New("down"))
+
+	for _, outcome := range outcomes {
+		assert.Equal(t, before[outcome]+1, testutil.ToFloat64(writeOutcomes.WithLabelValues(outcome)), outcome)
+	}
+}
+
+func TestMetricsHandlerServesEveryMetric(t *testing.T) {
+	// a vector is only served once it has a metric for some label value
+	operationDuration.WithLabelValues("test")
+	writeOutcomes.WithLabelValues(writtenOutcome)
+	neo4jErrors.WithLabelValues("other")
+
+	w := httptest.NewRecorder()
+	MetricsHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	for _, name := range []string{"people_rw_neo4j_operation_duration_seconds", "people_rw_neo4j_cypher_batch_statements", "people_rw_neo4j_writes_total", "people_rw_neo4j_neo4j_errors_total",
+		"people_rw_neo4j_identifier_sweeper_removed_total", "people_rw_neo4j_identifier_sweeper_last_removed", "people_rw_neo4j_identifier_sweeper_failures_total"} {
+		assert.Contains(t, w.Body.String(), "# TYPE "+name+" ")
+	}
+}
+
+func TestIdentifierSweepsAreCountedForPrometheus(t *testing.T) {
+	removedBefore := testutil.ToFloat64(sweptIdentifiers)
+	failuresBefore := testutil.ToFloat64(sweepFailures)
+
+	sweeper := NewIdentifierSweeper(NewCypherPeopleService(answering(`[{"count":2}]`)), time.Hour, 10)
+	removed, err := sweeper.Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, removedBefore+2, testutil.ToFloat64(sweptIdentifiers))
+	assert.Equal(t, float64(2), testutil.ToFloat64(lastSweptIdentifiers))
+
+	sweeper = NewIdentifierSweeper(NewCypherPeopleService(fakeConnection{err: errors.New("Neo4j is down")}), time.Hour, 10)
+	_, err = sweeper.Sweep()
+	assert.Error(t, err)
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(sweepFailures))
+	assert.Equal(t, float64(0), testutil.ToFloat64(lastSweptIdentifiers))
+}
+
+func observations(o prometheus.Observer) uint64 {
+	m := &dto.Metric{}
+	o.(prometheus.Metric).Write(m)
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestHealthChecksAreNotMeasuredAsOperations(t *testing.T) {
+	countsBefore := observations(operationDuration.WithLabelValues("count"))
+	batchesBefore := observations(cypherBatchSize)
+
+	message, err := NewCypherPeopleService(answering(`[{"c":3}]`)).CheckPeopleLoaded()
+	assert.NoError(t, err)
+	assert.Equal(t, "3 people loaded", message)
+
+	assert.Equal(t, countsBefore, observations(operationDuration.WithLabelValues("count")))
+	assert.Equal(t, batchesBefore, observations(cypherBatchSize))
+}
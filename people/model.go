@@ -3,19 +3,53 @@ package people
 type person struct {
 	UUID                   string                 `json:"uuid"`
 	BirthYear              int                    `json:"birthYear,omitempty"`
+	DateOfBirth            string                 `json:"dateOfBirth,omitempty"`
+	DateOfDeath            string                 `json:"dateOfDeath,omitempty"`
 	AlternativeIdentifiers alternativeIdentifiers `json:"alternativeIdentifiers"`
 	Name                   string                 `json:"name,omitempty"`
 	PrefLabel              string                 `json:"prefLabel"`
+	PrefLabels             map[string]string      `json:"prefLabels,omitempty"`
 	Salutation             string                 `json:"salutation,omitempty"`
 	Aliases                []string               `json:"aliases,omitempty"`
+	LanguageAliases        map[string][]string    `json:"languageAliases,omitempty"`
 	EmailAddress           string                 `json:"emailAddress,omitempty"`
 	TwitterHandle          string                 `json:"twitterHandle,omitempty"`
 	FacebookProfile        string                 `json:"facebookProfile,omitempty"`
 	LinkedinProfile        string                 `json:"linkedinProfile,omitempty"`
+	Profiles               []profile              `json:"profiles,omitempty"`
 	Description            string                 `json:"description,omitempty"`
 	DescriptionXML         string                 `json:"descriptionXML,omitempty"`
-	ImageURL               string                 `json:"_imageUrl,omitempty"` // TODO this is a temporary thing - needs to be integrated into images properly
+	Images                 []image                `json:"images,omitempty"`
+	ImageURL               string                 `json:"_imageUrl,omitempty"` // derived from the primary headshot in Images, kept for existing clients
 	Types                  []string               `json:"types,omitempty"`
+	Memberships            []membership           `json:"memberships,omitempty"`
+}
+
+type membership struct {
+	UUID             string   `json:"uuid,omitempty"`
+	OrganisationUUID string   `json:"organisationUuid"`
+	RoleUUIDs        []string `json:"roleUuids,omitempty"`
+	InceptionDate    string   `json:"inceptionDate,omitempty"`
+	TerminationDate  string   `json:"terminationDate,omitempty"`
+}
+
+type profile struct {
+	Network string `json:"network"`
+	Handle  string `json:"handle,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+type image struct {
+	UUID     string         `json:"uuid"`
+	Role     string         `json:"role"`
+	Variants []imageVariant `json:"variants,omitempty"`
+}
+
+type imageVariant struct {
+	URL    string `json:"url"`
+	Crop   string `json:"crop,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
 }
 
 type identifier struct {
@@ -24,13 +58,19 @@ type identifier struct {
 }
 
 type alternativeIdentifiers struct {
-	TME               []string `json:"TME,omitempty"`
-	UUIDS             []string `json:"uuids"`
-	FactsetIdentifier string   `json:"factsetIdentifier,omitempty"`
+	TME   []string `json:"TME,omitempty"`
+	UUIDS []string `json:"uuids"`
+	// FactsetIdentifier is superseded by FactsetIdentifiers, but still accepted, and returned to version 1 clients
+	FactsetIdentifier  string       `json:"factsetIdentifier,omitempty"`
+	FactsetIdentifiers []string     `json:"factsetIdentifiers,omitempty"`
+	WikidataIdentifier string       `json:"wikidataIdentifier,omitempty"`
+	SameAs             []string     `json:"sameAs,omitempty"`
+	Identifiers        []identifier `json:"identifiers,omitempty"`
 }
 
 const (
 	tmeIdentifierLabel     = "TMEIdentifier"
 	uppIdentifierLabel     = "UPPIdentifier"
 	factsetIdentifierLabel = "FactsetIdentifier"
+	membershipLabel        = "Membership"
 )
@@ -1,79 +1,131 @@
 package people
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/Financial-Times/neo-utils-go/neoutils"
 	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	log "github.com/Sirupsen/logrus"
 	"github.com/jmcvetta/neoism"
+	"github.com/pborman/uuid"
 )
 
 type service struct {
-	conn neoutils.NeoConnection
+	conn        neoutils.NeoConnection
+	authorities authorityRegistry
+	timeouts    Timeouts
+	retries     RetryPolicy
+	breaker     *circuitBreaker
 }
 
 // NewCypherPeopleService provides functions for create, update, delete operations on people in Neo4j,
 // plus other utility functions needed for a service
 func NewCypherPeopleService(cypherRunner neoutils.NeoConnection) service {
-	return service{cypherRunner}
+	authorities, _ := newAuthorityRegistry(DefaultAuthorities())
+	return service{instrumentedConnection{cypherRunner}, authorities, DefaultTimeouts(), DefaultRetryPolicy(),
+		newCircuitBreaker(DefaultCircuitBreakerSettings())}
+}
+
+// NewCypherPeopleServiceWithAuthorities is NewCypherPeopleService for a configured set of identifier authorities
+func NewCypherPeopleServiceWithAuthorities(cypherRunner neoutils.NeoConnection, authorities []Authority) (service, error) {
+	registry, err := newAuthorityRegistry(authorities)
+	if err != nil {
+		return service{}, err
+	}
+	return service{instrumentedConnection{cypherRunner}, registry, DefaultTimeouts(), DefaultRetryPolicy(),
+		newCircuitBreaker(DefaultCircuitBreakerSettings())}, nil
 }
 
 func (s service) Initialise() error {
+	indexes, constraints := s.schema()
 
-	err := s.conn.EnsureIndexes(map[string]string{
-		"Identifier": "value",
-	})
+	err := s.conn.EnsureIndexes(indexes)
 
 	if err != nil {
 		return err
 	}
 
-	return s.conn.EnsureConstraints(map[string]string{
-		"Thing":             "uuid",
-		"Concept":           "uuid",
-		"Person":            "uuid",
-		"FactsetIdentifier": "value",
-		"TMEIdentifier":     "value",
-		"UPPIdentifier":     "value"})
+	return s.conn.EnsureConstraints(constraints)
 }
 
 func (s service) Read(uuid string, transactionId string) (interface{}, bool, error) {
-	results := []person{}
+	return s.ReadContext(context.Background(), uuid, transactionId)
+}
+
+// ReadContext is Read giving up when the context is done, or after the read timeout
+func (s service) ReadContext(ctx context.Context, uuid string, transactionId string) (interface{}, bool, error) {
+	defer observeOperation("read", time.Now())
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+
+	results := []storedPerson{}
 
 	readQuery := &neoism.CypherQuery{
 		Statement: `MATCH (p:Person {uuid:{uuid}})
-					OPTIONAL MATCH (upp:UPPIdentifier)-[:IDENTIFIES]->(p)
-					OPTIONAL MATCH (factset:FactsetIdentifier)-[:IDENTIFIES]->(p)
-					OPTIONAL MATCH (tme:TMEIdentifier)-[:IDENTIFIES]->(p)
+					OPTIONAL MATCH (i:Identifier)-[:IDENTIFIES]->(p)
+					WITH p, i ORDER BY i.value
 					return p.uuid as uuid,
 						p.name as name,
 						p.emailAddress as emailAddress,
 						p.twitterHandle as twitterHandle,
 						p.facebookProfile as facebookProfile,
 						p.linkedinProfile as linkedinProfile,
+						p.profileNetworks as profileNetworks,
+						p.profileHandles as profileHandles,
+						p.profileUrls as profileUrls,
 						p.description as description,
 						p.descriptionXML as descriptionXML,
 						p.prefLabel as prefLabel,
 						p.birthYear as birthYear,
+						p.dateOfBirth as dateOfBirth,
+						p.dateOfDeath as dateOfDeath,
 						p.salutation as salutation,
 						p.aliases as aliases,
+						p.prefLabelLanguages as prefLabelLanguages,
+						p.prefLabelValues as prefLabelValues,
+						p.aliasLanguages as aliasLanguages,
+						p.aliasValues as aliasValues,
 						p.imageUrl as _imageUrl,
+						p.factsetIdentifier as factsetIdentifier,
 						labels(p) as types,
-						{uuids:collect(distinct upp.value),
-							TME:collect(distinct tme.value),
-							factsetIdentifier:factset.value} as alternativeIdentifiers`,
+						[i IN collect(i) | {labels:labels(i), value:i.value}] as identifiers`,
 		Parameters: map[string]interface{}{
 			"uuid": uuid,
 		},
 		Result: &results,
 	}
 
-	if err := s.conn.CypherBatch([]*neoism.CypherQuery{readQuery}); err != nil || len(results) == 0 {
+	memberships := []membership{}
+
+	readMembershipsQuery := &neoism.CypherQuery{
+		Statement: `MATCH (m:Membership)-[:HAS_MEMBER]->(:Person {uuid:{uuid}})
+					OPTIONAL MATCH (m)-[:HAS_ORGANISATION]->(o:Thing)
+					OPTIONAL MATCH (m)-[:HAS_ROLE]->(r:Thing)
+					WITH m, o, r ORDER BY r.uuid
+					RETURN m.uuid as uuid,
+						o.uuid as organisationUuid,
+						collect(r.uuid) as roleUuids,
+						m.inceptionDate as inceptionDate,
+						m.terminationDate as terminationDate
+					ORDER BY uuid`,
+		Parameters: map[string]interface{}{
+			"uuid": uuid,
+		},
+		Result: &memberships,
+	}
+
+	images := []storedImage{}
+
+	if err := s.cypherBatchContext(ctx, transactionId, "read", []*neoism.CypherQuery{readQuery, readMembershipsQuery, readImagesQuery(uuid, &images)}); err != nil {
 		return person{}, false, err
 	}
 
 	if len(results) == 0 {
+		transactionLogger(transactionId).WithField("uuid", uuid).Debug("Person not found")
 		return person{}, false, nil
 	}
 	result := results[0]
@@ -86,21 +138,121 @@ func (s service) Read(uuid string, transactionId string) (interface{}, bool, err
 		TwitterHandle:          result.TwitterHandle,
 		FacebookProfile:        result.FacebookProfile,
 		LinkedinProfile:        result.LinkedinProfile,
+		Profiles:               unflattenProfiles(result.ProfileNetworks, result.ProfileHandles, result.ProfileURLs),
 		Description:            result.Description,
 		DescriptionXML:         result.DescriptionXML,
 		BirthYear:              result.BirthYear,
+		DateOfBirth:            result.DateOfBirth,
+		DateOfDeath:            result.DateOfDeath,
 		Salutation:             result.Salutation,
 		ImageURL:               result.ImageURL,
-		AlternativeIdentifiers: result.AlternativeIdentifiers,
+		AlternativeIdentifiers: s.alternativeIdentifiers(result.Identifiers).withFactsetIdentifierFirst(result.FactsetIdentifier),
 		Aliases:                result.Aliases,
+		PrefLabels:             unflattenLabels(result.PrefLabelLanguages, result.PrefLabelValues),
+		LanguageAliases:        unflattenAliases(result.AliasLanguages, result.AliasValues),
 		Types:                  result.Types,
 	}
 
+	if len(memberships) > 0 {
+		p.Memberships = memberships
+	}
+
+	for _, i := range images {
+		p.Images = append(p.Images, i.image())
+	}
+	if url := primaryImageURL(p.Images); url != "" {
+		p.ImageURL = url
+	}
+
 	return p, true, nil
 
 }
 
+// storedPerson is a person as read from Neo4j, where language tagged labels and profiles are held as parallel lists
+type storedPerson struct {
+	person
+	ProfileNetworks    []string           `json:"profileNetworks"`
+	ProfileHandles     []string           `json:"profileHandles"`
+	ProfileURLs        []string           `json:"profileUrls"`
+	PrefLabelLanguages []string           `json:"prefLabelLanguages"`
+	PrefLabelValues    []string           `json:"prefLabelValues"`
+	AliasLanguages     []string           `json:"aliasLanguages"`
+	AliasValues        []string           `json:"aliasValues"`
+	Identifiers        []storedIdentifier `json:"identifiers"`
+	FactsetIdentifier  string             `json:"factsetIdentifier"`
+}
+
+type storedIdentifier struct {
+	Labels []string `json:"labels"`
+	Value  string   `json:"value"`
+}
+
+// alternativeIdentifiers sorts the Identifier nodes of a person into the fields for their authorities
+func (s service) alternativeIdentifiers(stored []storedIdentifier) alternativeIdentifiers {
+	ids := alternativeIdentifiers{TME: []string{}, UUIDS: []string{}}
+
+	for _, i := range stored {
+		authority, found := s.authorities.authority(i.Labels)
+		if !found {
+			// written for an authority which is no longer configured
+			continue
+		}
+		switch authority {
+		case tmeAuthority:
+			ids.TME = append(ids.TME, i.Value)
+		case uppAuthority:
+			ids.UUIDS = append(ids.UUIDS, i.Value)
+		case factsetAuthority:
+			ids.FactsetIdentifiers = append(ids.FactsetIdentifiers, i.Value)
+		case wikidataAuthority:
+			ids.WikidataIdentifier = i.Value
+		case sameAsAuthority:
+			ids.SameAs = append(ids.SameAs, i.Value)
+		default:
+			ids.Identifiers = append(ids.Identifiers, identifier{Authority: authority, IdentifierValue: i.Value})
+		}
+	}
+	sortIdentifiers(ids.Identifiers)
+
+	return ids
+}
+
+// Lookup finds the uuid of the person with the given identifier from an authority
+func (s service) Lookup(authority string, value string) (string, bool, error) {
+	label, found := s.authorities.label(authority)
+	if !found {
+		return "", false, requestError{fmt.Sprintf("Unknown identifier authority %s", authority)}
+	}
+
+	results := []struct {
+		UUID string `json:"uuid"`
+	}{}
+
+	query := &neoism.CypherQuery{
+		Statement: fmt.Sprintf(`MATCH (:%s {value:{value}})-[:IDENTIFIES]->(p:Person)
+					RETURN p.uuid as uuid`, label),
+		Parameters: map[string]interface{}{
+			"value": value,
+		},
+		Result: &results,
+	}
+
+	if err := s.cypherBatch("", "lookup", []*neoism.CypherQuery{query}); err != nil || len(results) == 0 {
+		return "", false, err
+	}
+
+	return results[0].UUID, true, nil
+}
+
 func (s service) IDs(f func(id rwapi.IDEntry) (bool, error)) error {
+	return s.IDsContext(context.Background(), f)
+}
+
+// IDsContext is IDs giving up when the context is done, or when a page of ids takes longer than the ids timeout.
+// The whole stream is not limited, as it can take as long as the client takes to read it.
+func (s service) IDsContext(ctx context.Context, f func(id rwapi.IDEntry) (bool, error)) error {
+	defer observeOperation("ids", time.Now())
+
 	batchSize := 4096
 
 	for skip := 0; ; skip += batchSize {
@@ -113,7 +265,10 @@ func (s service) IDs(f func(id rwapi.IDEntry) (bool, error)) error {
 			},
 			Result: &results,
 		}
-		if err := s.conn.CypherBatch([]*neoism.CypherQuery{readQuery}); err != nil {
+		pageCtx, cancel := withTimeout(ctx, s.timeouts.IDs)
+		err := s.cypherBatchContext(pageCtx, "", "ids", []*neoism.CypherQuery{readQuery})
+		cancel()
+		if err != nil {
 			return err
 		}
 		if len(results) == 0 {
@@ -129,13 +284,94 @@ func (s service) IDs(f func(id rwapi.IDEntry) (bool, error)) error {
 }
 
 func (s service) Write(thing interface{}, transactionId string) error {
+	return s.WriteContext(context.Background(), thing, transactionId)
+}
+
+// WriteContext is Write giving up when the context is done, or after the write timeout. The write may still be
+// committed by Neo4j after giving up.
+func (s service) WriteContext(ctx context.Context, thing interface{}, transactionId string) error {
+	defer observeOperation("write", time.Now())
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
 
 	hash, err := writeHash(thing)
 	if err != nil {
 		return err
 	}
 
-	p := thing.(person)
+	return s.write(ctx, thing.(person), hash, transactionId)
+}
+
+func (s service) write(ctx context.Context, p person, hash string, transactionId string) error {
+	var sameHash bool
+	err := s.retryTransient(ctx, "write", transactionId, func() (err error) {
+		sameHash, err = s.writePerson(ctx, p, hash, transactionId)
+		return err
+	})
+	recordWriteOutcome(sameHash, err)
+
+	logger := transactionLogger(transactionId).WithField("uuid", p.UUID)
+	switch e := err.(type) {
+	case nil:
+		logger = logger.WithFields(log.Fields{
+			"identifiers": len(p.AlternativeIdentifiers.byAuthority()),
+			"memberships": len(p.Memberships),
+			"images":      len(p.Images),
+		})
+		if sameHash {
+			// written all the same, so anything missing from the graph is put back
+			logger.Debug("Person rewritten with the same hash as the last write, identifiers replaced")
+		} else {
+			logger.Debug("Person written, identifiers replaced")
+		}
+	case requestError:
+		logger.WithField("details", e.details).Info("Rejected invalid person")
+	default:
+		logger.WithError(err).Error("Failed to write person")
+	}
+	return err
+}
+
+// writePerson writes the person, reporting whether the hash is the same as before. They are written either way.
+func (s service) writePerson(ctx context.Context, p person, hash string, transactionId string) (bool, error) {
+	p.AlternativeIdentifiers = p.AlternativeIdentifiers.normalised()
+
+	birth, death, err := validateLifeDates(p)
+	if err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	if err := validateLanguageTags(p); err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	if err := validateIdentifiers(p.AlternativeIdentifiers); err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	if err := validateWikidataAndSameAs(p.AlternativeIdentifiers); err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	if err := validateImages(p.Images, p.ImageURL); err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	p, err = normaliseProfiles(p)
+	if err != nil {
+		return false, requestError{err.Error()}
+	}
+
+	if p.DescriptionXML != "" {
+		sanitised, text, err := sanitiseDescriptionXML(p.DescriptionXML)
+		if err != nil {
+			return false, requestError{err.Error()}
+		}
+		p.DescriptionXML = sanitised
+		if p.Description == "" {
+			p.Description = text
+		}
+	}
 
 	params := map[string]interface{}{
 		"uuid": p.UUID,
@@ -148,12 +384,30 @@ func (s service) Write(thing interface{}, transactionId string) error {
 
 	if p.PrefLabel != "" {
 		params["prefLabel"] = p.PrefLabel
+	} else if label, found := p.PrefLabels["en"]; found {
+		params["prefLabel"] = label
+	}
+
+	if len(p.PrefLabels) > 0 {
+		params["prefLabelLanguages"], params["prefLabelValues"] = flattenLabels(p.PrefLabels)
 	}
 
 	if p.BirthYear != 0 {
 		params["birthYear"] = p.BirthYear
 	}
 
+	if p.DateOfBirth != "" {
+		params["dateOfBirth"] = p.DateOfBirth
+		params["dateOfBirthPrecision"] = birth.precision
+		// existing clients only know about birthYear
+		params["birthYear"] = birth.date.Year()
+	}
+
+	if p.DateOfDeath != "" {
+		params["dateOfDeath"] = p.DateOfDeath
+		params["dateOfDeathPrecision"] = death.precision
+	}
+
 	if p.Salutation != "" {
 		params["salutation"] = p.Salutation
 	}
@@ -170,10 +424,14 @@ func (s service) Write(thing interface{}, transactionId string) error {
 		params["facebookProfile"] = p.FacebookProfile
 	}
 
-	if p.FacebookProfile != "" {
+	if p.LinkedinProfile != "" {
 		params["linkedinProfile"] = p.LinkedinProfile
 	}
 
+	if len(p.Profiles) > 0 {
+		params["profileNetworks"], params["profileHandles"], params["profileUrls"] = flattenProfiles(p.Profiles)
+	}
+
 	if p.Description != "" {
 		params["description"] = p.Description
 	}
@@ -182,7 +440,13 @@ func (s service) Write(thing interface{}, transactionId string) error {
 		params["descriptionXML"] = p.DescriptionXML
 	}
 
-	if p.ImageURL != "" {
+	if len(p.AlternativeIdentifiers.FactsetIdentifiers) > 0 {
+		// normalised puts one given as factsetIdentifier first, and version 1 clients still expect to get it back
+		params["factsetIdentifier"] = p.AlternativeIdentifiers.FactsetIdentifiers[0]
+	}
+
+	// only kept for writers which have not moved to images yet
+	if p.ImageURL != "" && len(p.Images) == 0 {
 		params["imageUrl"] = p.ImageURL
 	}
 
@@ -196,6 +460,10 @@ func (s service) Write(thing interface{}, transactionId string) error {
 		params["aliases"] = aliases
 	}
 
+	if languages, values := flattenAliases(p.LanguageAliases); len(values) > 0 {
+		params["aliasLanguages"], params["aliasValues"] = languages, values
+	}
+
 	deleteEntityRelationshipsQuery := &neoism.CypherQuery{
 		Statement: `MATCH (i:Identifier)-[ir:IDENTIFIES]->(t:Thing {uuid:{uuid}})
 				DELETE ir, i`,
@@ -204,7 +472,52 @@ func (s service) Write(thing interface{}, transactionId string) error {
 		},
 	}
 
-	queries := []*neoism.CypherQuery{deleteEntityRelationshipsQuery}
+	previous := []struct {
+		Hash string `json:"hash"`
+	}{}
+	previousHashQuery := &neoism.CypherQuery{
+		Statement: `MATCH (p:Person {uuid:{uuid}}) RETURN p.hash as hash`,
+		Parameters: map[string]interface{}{
+			"uuid": p.UUID,
+		},
+		Result: &previous,
+	}
+
+	queries := []*neoism.CypherQuery{previousHashQuery, deleteEntityRelationshipsQuery}
+
+	membershipUUIDs := []string{}
+	seenMemberships := make(map[string]bool)
+	for _, m := range p.Memberships {
+		if m.OrganisationUUID == "" {
+			return false, requestError{fmt.Sprintf("Membership of person %s has no organisationUuid", p.UUID)}
+		}
+		if m.UUID == "" {
+			m.UUID = membershipUUID(p.UUID, m)
+		}
+		if seenMemberships[m.UUID] {
+			// the second would silently overwrite the first
+			return false, requestError{fmt.Sprintf("Person %s has more than one membership %s, give them different uuids", p.UUID, m.UUID)}
+		}
+		seenMemberships[m.UUID] = true
+		membershipUUIDs = append(membershipUUIDs, m.UUID)
+	}
+
+	if err := s.checkMembershipOwners(ctx, p.UUID, membershipUUIDs, transactionId); err != nil {
+		return false, err
+	}
+
+	// memberships dropped from the person are removed, the rest are rewritten below
+	deleteStaleMembershipsQuery := &neoism.CypherQuery{
+		Statement: `MATCH (m:Membership)-[:HAS_MEMBER]->(:Thing {uuid:{uuid}})
+				WHERE NOT m.uuid IN {membershipUuids}
+				DETACH DELETE m`,
+		Parameters: map[string]interface{}{
+			"uuid":            p.UUID,
+			"membershipUuids": membershipUUIDs,
+		},
+	}
+
+	queries = append(queries, deleteStaleMembershipsQuery)
 
 	writeQuery := &neoism.CypherQuery{
 		Statement: `MERGE (n:Thing{uuid: {uuid}})
@@ -220,21 +533,127 @@ func (s service) Write(thing interface{}, transactionId string) error {
 	queries = append(queries, writeQuery)
 
 	//ADD all the IDENTIFIER nodes and IDENTIFIES relationships
-	for _, alternativeUUID := range p.AlternativeIdentifiers.TME {
-		alternativeIdentifierQuery := createNewIdentifierQuery(p.UUID, tmeIdentifierLabel, alternativeUUID)
-		queries = append(queries, alternativeIdentifierQuery)
+	for _, id := range p.AlternativeIdentifiers.byAuthority() {
+		label, found := s.authorities.label(id.Authority)
+		if !found {
+			return false, requestError{fmt.Sprintf("Unknown identifier authority %s", id.Authority)}
+		}
+		queries = append(queries, createNewIdentifierQuery(p.UUID, label, id.IdentifierValue))
 	}
 
-	for _, alternativeUUID := range p.AlternativeIdentifiers.UUIDS {
-		alternativeIdentifierQuery := createNewIdentifierQuery(p.UUID, uppIdentifierLabel, alternativeUUID)
-		queries = append(queries, alternativeIdentifierQuery)
+	for i, m := range p.Memberships {
+		m.UUID = membershipUUIDs[i]
+		queries = append(queries, createMembershipQuery(p.UUID, m))
 	}
 
-	if p.AlternativeIdentifiers.FactsetIdentifier != "" {
-		queries = append(queries, createNewIdentifierQuery(p.UUID, factsetIdentifierLabel, p.AlternativeIdentifiers.FactsetIdentifier))
+	queries = append(queries, deleteImagesQuery(p.UUID))
+	for position, i := range p.Images {
+		queries = append(queries, createImageQuery(p.UUID, position, i))
+	}
+
+	if err := s.cypherBatchContext(ctx, transactionId, "write", queries); err != nil {
+		return false, err
 	}
+	return len(previous) > 0 && previous[0].Hash == hash, nil
+}
 
-	return s.conn.CypherBatch(queries)
+// membershipUUID derives a stable uuid for a membership supplied without one, so rewriting the same person
+// updates the membership rather than replacing it. The roles are part of it, so that someone holding different roles
+// at the same organisation from the same date has a membership for each.
+func membershipUUID(personUUID string, m membership) string {
+	roles := append([]string{}, m.RoleUUIDs...)
+	sort.Strings(roles)
+	name := m.OrganisationUUID + m.InceptionDate
+	for _, role := range roles {
+		name += "/" + role
+	}
+	return uuid.NewMD5(uuid.Parse(personUUID), []byte(name)).String()
+}
+
+// checkMembershipOwners rejects memberships which already belong to someone else, as rewriting them would move them
+// to this person
+func (s service) checkMembershipOwners(ctx context.Context, personUUID string, membershipUUIDs []string, transactionId string) error {
+	if len(membershipUUIDs) == 0 {
+		return nil
+	}
+
+	owners := []struct {
+		Membership string `json:"membership"`
+		Owner      string `json:"owner"`
+	}{}
+	query := &neoism.CypherQuery{
+		Statement: `MATCH (m:Thing)-[:HAS_MEMBER]->(owner:Thing)
+				WHERE m.uuid IN {membershipUuids} AND owner.uuid <> {uuid}
+				RETURN m.uuid as membership, owner.uuid as owner
+				ORDER BY membership`,
+		Parameters: map[string]interface{}{
+			"uuid":            personUUID,
+			"membershipUuids": membershipUUIDs,
+		},
+		Result: &owners,
+	}
+	if err := s.cypherBatchContext(ctx, transactionId, "membership owners", []*neoism.CypherQuery{query}); err != nil {
+		return err
+	}
+
+	if len(owners) > 0 {
+		return rwapi.ConstraintOrTransactionError{
+			Message: fmt.Sprintf("Membership %s belongs to person %s, not %s", owners[0].Membership, owners[0].Owner, personUUID),
+		}
+	}
+	return nil
+}
+
+// createMembershipQuery writes the membership unless it belongs to someone else. checkMembershipOwners has already
+// rejected those, this only stops one being taken over by a write racing with the check.
+func createMembershipQuery(personUUID string, m membership) *neoism.CypherQuery {
+	props := map[string]interface{}{
+		"uuid": m.UUID,
+	}
+
+	if m.InceptionDate != "" {
+		props["inceptionDate"] = m.InceptionDate
+	}
+
+	if m.TerminationDate != "" {
+		props["terminationDate"] = m.TerminationDate
+	}
+
+	roleUUIDs := m.RoleUUIDs
+	if roleUUIDs == nil {
+		roleUUIDs = []string{}
+	}
+
+	return &neoism.CypherQuery{
+		Statement: fmt.Sprintf(`MERGE (p:Thing {uuid:{personUuid}})
+					MERGE (m:Thing {uuid:{uuid}})
+					WITH p, m
+					OPTIONAL MATCH (m)-[:HAS_MEMBER]->(owner:Thing)
+					WHERE owner.uuid <> {personUuid}
+					WITH p, m, owner
+					WHERE owner IS NULL
+					set m={props}
+					set m :Concept
+					set m :%s
+					WITH p, m
+					OPTIONAL MATCH (m)-[rel:HAS_MEMBER|HAS_ORGANISATION|HAS_ROLE]->()
+					DELETE rel
+					WITH DISTINCT p, m
+					MERGE (o:Thing {uuid:{organisationUuid}})
+					CREATE (m)-[:HAS_MEMBER]->(p)
+					CREATE (m)-[:HAS_ORGANISATION]->(o)
+					WITH m
+					UNWIND {roleUuids} AS roleUuid
+					MERGE (r:Thing {uuid:roleUuid})
+					CREATE (m)-[:HAS_ROLE]->(r)`, membershipLabel),
+		Parameters: map[string]interface{}{
+			"personUuid":       personUUID,
+			"uuid":             m.UUID,
+			"props":            props,
+			"organisationUuid": m.OrganisationUUID,
+			"roleUuids":        roleUUIDs,
+		},
+	}
 }
 
 func createNewIdentifierQuery(uuid string, identifierLabel string, identifierValue string) *neoism.CypherQuery {
@@ -252,6 +671,32 @@ func createNewIdentifierQuery(uuid string, identifierLabel string, identifierVal
 }
 
 func (s service) Delete(uuid string, transactionId string) (bool, error) {
+	return s.DeleteWithCascade(uuid, transactionId, "")
+}
+
+// DeleteContext is Delete giving up when the context is done, or after the delete timeout
+func (s service) DeleteContext(ctx context.Context, uuid string, transactionId string) (bool, error) {
+	return s.DeleteWithCascadeContext(ctx, uuid, transactionId, "")
+}
+
+// DeleteWithCascade deletes the person, also removing what cascade lists as owned by them. Only "memberships" can
+// be given.
+func (s service) DeleteWithCascade(uuid string, transactionId string, cascade string) (bool, error) {
+	return s.DeleteWithCascadeContext(context.Background(), uuid, transactionId, cascade)
+}
+
+// DeleteWithCascadeContext is DeleteWithCascade giving up when the context is done, or after the delete timeout. The
+// delete may still be committed by Neo4j after giving up.
+func (s service) DeleteWithCascadeContext(ctx context.Context, uuid string, transactionId string, cascade string) (bool, error) {
+	defer observeOperation("delete", time.Now())
+	ctx, cancel := withTimeout(ctx, s.timeouts.Delete)
+	defer cancel()
+
+	options, err := parseCascade(cascade)
+	if err != nil {
+		return false, requestError{err.Error()}
+	}
+
 	clearNode := &neoism.CypherQuery{
 		Statement: `
 			MATCH (p:Thing {uuid: {uuid}})
@@ -282,9 +727,24 @@ func (s service) Delete(uuid string, transactionId string) (bool, error) {
 		Parameters: map[string]interface{}{
 			"uuid": uuid,
 		},
+		IncludeStats: true,
 	}
 
-	err := s.conn.CypherBatch([]*neoism.CypherQuery{clearNode, removeNodeIfUnused})
+	// images belong to the person, so must go before checking whether anything else still refers to it
+	queries := []*neoism.CypherQuery{clearNode, deleteImagesQuery(uuid)}
+	for _, option := range options {
+		if option == cascadeMemberships {
+			queries = append(queries, deleteMembershipsQuery(uuid))
+		}
+	}
+	queries = append(queries, removeNodeIfUnused)
+
+	err = s.retryTransient(ctx, "delete", transactionId, func() error {
+		return s.cypherBatchContext(ctx, transactionId, "delete", queries)
+	})
+	if err != nil {
+		return false, err
+	}
 
 	s1, err := clearNode.Stats()
 
@@ -297,12 +757,23 @@ func (s service) Delete(uuid string, transactionId string) (bool, error) {
 		deleted = true
 	}
 
+	logger := transactionLogger(transactionId).WithFields(log.Fields{"uuid": uuid, "cascade": options})
+	if !deleted {
+		logger.Debug("Person not found to delete")
+	} else if s2, err := removeNodeIfUnused.Stats(); err == nil && s2.NodesDeleted == 0 {
+		logger.Debug("Person deleted, leaving a Thing behind as other Things still refer to it")
+	} else {
+		logger.Debug("Person deleted")
+	}
+
 	return deleted, err
 }
 
 func (s service) DecodeJSON(dec *json.Decoder) (interface{}, string, error) {
 	p := person{}
 	err := dec.Decode(&p)
+	// factset identifiers are not normalised until written, as the hash is of the person as supplied. Otherwise the
+	// hashes of everyone written with factsetIdentifier would change, and reconcile would report them all.
 	return p, p.UUID, err
 }
 
@@ -311,6 +782,18 @@ func (s service) Check() error {
 }
 
 func (s service) Count() (int, error) {
+	return s.CountContext(context.Background())
+}
+
+// CountContext is Count giving up when the context is done, or after the count timeout
+func (s service) CountContext(ctx context.Context) (int, error) {
+	defer observeOperation("count", time.Now())
+	return s.count(ctx)
+}
+
+func (s service) count(ctx context.Context) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Count)
+	defer cancel()
 
 	results := []struct {
 		Count int `json:"c"`
@@ -321,7 +804,7 @@ func (s service) Count() (int, error) {
 		Result:    &results,
 	}
 
-	err := s.conn.CypherBatch([]*neoism.CypherQuery{query})
+	err := s.cypherBatchContext(ctx, "", "count", []*neoism.CypherQuery{query})
 
 	if err != nil {
 		return 0, err
@@ -3,11 +3,14 @@
 package people
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"encoding/json"
 
@@ -28,6 +31,13 @@ const (
 	fullPersonThirdUuid  = "38431a92-dda3-4eb9-a367-60145a8e659f"
 	uniquePersonUuid     = "bb596d64-78c5-4b00-a88f-e8248c956073"
 	contentUUID          = "3fc9fe3e-af8c-4f7f-961a-e5065392bb31"
+	firstMembershipUuid  = "5a3b0a4e-3f4f-4bb8-9d68-4b9b6bbbd5f0"
+	secondMembershipUuid = "a7e9c2a6-7e5e-4c0f-8a59-6a2f6b9d2e11"
+	organisationUuid     = "f1e6e9a8-0b7e-4d4b-9c8c-4e2cfb6e1d7a"
+	firstRoleUuid        = "0e3c8f5a-2c1d-4a6b-8b8e-1f6d3c2b9a40"
+	secondRoleUuid       = "6d2b1a9c-8e4f-4c3d-9a7b-5e0f1c2d3b84"
+	headshotImageUuid    = "c1d2e3f4-5a6b-4c7d-8e9f-0a1b2c3d4e5f"
+	bylineImageUuid      = "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
 )
 
 var minimalPerson = person{
@@ -311,6 +321,604 @@ func TestIDs(t *testing.T) {
 
 }
 
+func TestExportAndImportRestoresPeopleAndHashes(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{fullPersonUuid}, db, t, assert)
+
+	assert.NoError(peopleDriver.Write(fullPerson, "TEST_TRANS_ID"), "Failed to write person")
+	hashBefore := readHash(fullPersonUuid, peopleDriver, assert)
+
+	var dump bytes.Buffer
+	count, err := peopleDriver.Export(&dump)
+	assert.NoError(err)
+	assert.True(count > 0, "Expected at least one person to be exported")
+
+	cleanDB([]string{fullPersonUuid}, db, t, assert)
+
+	_, err = peopleDriver.Import(&dump)
+	assert.NoError(err)
+
+	readPeopleAndCompare(fullPerson, t, db)
+	assert.Equal(hashBefore, readHash(fullPersonUuid, peopleDriver, assert), "Hash should survive an export and import")
+}
+
+func TestReconcileReportsMissingChangedAndExtraPeople(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{fullPersonUuid, minimalPersonUuid}, db, t, assert)
+
+	assert.NoError(peopleDriver.Write(fullPerson, "TEST_TRANS_ID"), "Failed to write person")
+	assert.NoError(peopleDriver.Write(person{UUID: minimalPersonUuid, Name: "Minimal Person", AlternativeIdentifiers: alternativeIdentifiers{UUIDS: []string{minimalPersonUuid}}}, "TEST_TRANS_ID"), "Failed to write person")
+
+	result, err := peopleDriver.Reconcile(context.Background(), idSource(
+		rwapi.IDEntry{ID: uniquePersonUuid, Hash: "some-hash"},
+		rwapi.IDEntry{ID: fullPersonUuid, Hash: "not-the-stored-hash"},
+	))
+	assert.NoError(err)
+
+	assert.Equal([]string{uniquePersonUuid}, result.Missing)
+	assert.Equal([]string{fullPersonUuid}, result.Changed)
+	assert.Contains(result.Extra, minimalPersonUuid)
+	assert.NotContains(result.Extra, fullPersonUuid)
+
+	unsorted, err := peopleDriver.Reconcile(context.Background(), idSource(
+		rwapi.IDEntry{ID: fullPersonUuid, Hash: "not-the-stored-hash"},
+		rwapi.IDEntry{ID: uniquePersonUuid, Hash: "some-hash"},
+	))
+	assert.NoError(err, "A source not sorted by id should be sorted")
+	assert.Equal(result, unsorted)
+
+	_, err = peopleDriver.Reconcile(context.Background(), idSource(
+		rwapi.IDEntry{ID: fullPersonUuid, Hash: "some-hash"},
+		rwapi.IDEntry{ID: uniquePersonUuid, Hash: "some-hash"},
+		rwapi.IDEntry{ID: fullPersonUuid, Hash: "some-hash"},
+	))
+	assert.IsType(requestError{}, err, "A source listing a uuid twice should be rejected")
+}
+
+func idSource(ids ...rwapi.IDEntry) func() (rwapi.IDEntry, bool, error) {
+	return func() (rwapi.IDEntry, bool, error) {
+		if len(ids) == 0 {
+			return rwapi.IDEntry{}, false, nil
+		}
+		id := ids[0]
+		ids = ids[1:]
+		return id, true, nil
+	}
+}
+
+func TestMembershipsAreWrittenReadAndReplacedOnUpdate(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid, firstMembershipUuid, secondMembershipUuid, organisationUuid, firstRoleUuid, secondRoleUuid}, db, t, assert)
+
+	personWithMemberships := minimalPerson
+	personWithMemberships.Memberships = []membership{
+		{UUID: firstMembershipUuid, OrganisationUUID: organisationUuid, RoleUUIDs: []string{firstRoleUuid, secondRoleUuid}, InceptionDate: "2012-01-01", TerminationDate: "2015-06-30"},
+		{UUID: secondMembershipUuid, OrganisationUUID: organisationUuid, RoleUUIDs: []string{firstRoleUuid}, InceptionDate: "2015-07-01"},
+	}
+
+	assert.NoError(peopleDriver.Write(personWithMemberships, "TEST_TRANS_ID"), "Failed to write person")
+	readPeopleAndCompare(personWithMemberships, t, db)
+
+	personWithMemberships.Memberships = personWithMemberships.Memberships[1:]
+	assert.NoError(peopleDriver.Write(personWithMemberships, "TEST_TRANS_ID"), "Failed to write updated person")
+	readPeopleAndCompare(personWithMemberships, t, db)
+	assert.False(doesThingExistAtAll(firstMembershipUuid, db, t, assert), "Stale membership %s should have been removed", firstMembershipUuid)
+}
+
+func TestMembershipWithoutOrganisationIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithMemberships := minimalPerson
+	personWithMemberships.Memberships = []membership{{RoleUUIDs: []string{firstRoleUuid}}}
+
+	err := peopleDriver.Write(personWithMemberships, "TEST_TRANS_ID")
+	assert.IsType(requestError{}, err)
+}
+
+func TestMembershipsDifferingOnlyByRoleAreBothKept(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	firstMembership := membership{OrganisationUUID: organisationUuid, RoleUUIDs: []string{firstRoleUuid}, InceptionDate: "2012-01-01"}
+	secondMembership := membership{OrganisationUUID: organisationUuid, RoleUUIDs: []string{secondRoleUuid}, InceptionDate: "2012-01-01"}
+	defer cleanDB([]string{minimalPersonUuid, membershipUUID(minimalPersonUuid, firstMembership), membershipUUID(minimalPersonUuid, secondMembership), organisationUuid, firstRoleUuid, secondRoleUuid}, db, t, assert)
+
+	personWithMemberships := minimalPerson
+	personWithMemberships.Memberships = []membership{firstMembership, secondMembership}
+	assert.NoError(peopleDriver.Write(personWithMemberships, "TEST_TRANS_ID"), "Failed to write person")
+
+	assert.True(doesThingExistAtAll(membershipUUID(minimalPersonUuid, firstMembership), db, t, assert))
+	assert.True(doesThingExistAtAll(membershipUUID(minimalPersonUuid, secondMembership), db, t, assert))
+}
+
+func TestMembershipsWithTheSameUUIDAreRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithMemberships := minimalPerson
+	personWithMemberships.Memberships = []membership{
+		{OrganisationUUID: organisationUuid, RoleUUIDs: []string{firstRoleUuid, secondRoleUuid}, InceptionDate: "2012-01-01"},
+		{OrganisationUUID: organisationUuid, RoleUUIDs: []string{secondRoleUuid, firstRoleUuid}, InceptionDate: "2012-01-01"},
+	}
+
+	err := peopleDriver.Write(personWithMemberships, "TEST_TRANS_ID")
+	assert.IsType(requestError{}, err)
+}
+
+func TestMembershipOfAnotherPersonIsNotTakenOver(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid, fullPersonUuid, firstMembershipUuid, organisationUuid}, db, t, assert)
+
+	owner := minimalPerson
+	owner.Memberships = []membership{{UUID: firstMembershipUuid, OrganisationUUID: organisationUuid}}
+	assert.NoError(peopleDriver.Write(owner, "TEST_TRANS_ID"), "Failed to write person")
+
+	other := fullPerson
+	other.Memberships = []membership{{UUID: firstMembershipUuid, OrganisationUUID: organisationUuid, InceptionDate: "2012-01-01"}}
+	err := peopleDriver.Write(other, "TEST_TRANS_ID")
+	assert.IsType(rwapi.ConstraintOrTransactionError{}, err)
+
+	readPeopleAndCompare(owner, t, db)
+}
+
+func TestDateOfBirthAndDeathAreWrittenAndBirthYearDerived(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithDates := minimalPerson
+	personWithDates.DateOfBirth = "1974-03"
+	personWithDates.DateOfDeath = "2016-11-02"
+
+	assert.NoError(peopleDriver.Write(personWithDates, "TEST_TRANS_ID"), "Failed to write person")
+
+	personWithDates.BirthYear = 1974
+	readPeopleAndCompare(personWithDates, t, db)
+}
+
+func TestLanguageTaggedLabelsAndAliasesAreWrittenAndRead(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	multilingualPerson := minimalPerson
+	multilingualPerson.PrefLabels = map[string]string{"en": "Shinzo Abe", "ja": "安倍晋三", "zh-Hans": "安倍晋三"}
+	multilingualPerson.LanguageAliases = map[string][]string{"ja": {"安倍首相"}, "en": {"Abe Shinzo", "PM Abe"}}
+
+	assert.NoError(peopleDriver.Write(multilingualPerson, "TEST_TRANS_ID"), "Failed to write person")
+	readPeopleAndCompare(multilingualPerson, t, db)
+}
+
+func TestInvalidLanguageTagIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	multilingualPerson := minimalPerson
+	multilingualPerson.PrefLabels = map[string]string{"not a language": "Shinzo Abe"}
+
+	assert.IsType(requestError{}, peopleDriver.Write(multilingualPerson, "TEST_TRANS_ID"))
+}
+
+func TestIdentifiersFromOtherAuthoritiesAreWrittenAndRead(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithIdentifiers := minimalPerson
+	personWithIdentifiers.AlternativeIdentifiers.Identifiers = []identifier{
+		{Authority: companiesHouseOfficerAuthority, IdentifierValue: "Ab1cD2eF3gH4iJ5kL6mN7oP8qR9"},
+		{Authority: orcidAuthority, IdentifierValue: "0000-0002-1825-0097"},
+	}
+
+	assert.NoError(peopleDriver.Write(personWithIdentifiers, "TEST_TRANS_ID"), "Failed to write person")
+	readPeopleAndCompare(personWithIdentifiers, t, db)
+}
+
+func TestIdentifierFromUnknownAuthorityIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithIdentifiers := minimalPerson
+	personWithIdentifiers.AlternativeIdentifiers.Identifiers = []identifier{{Authority: "http://example.com/unknown", IdentifierValue: "1"}}
+
+	assert.IsType(requestError{}, peopleDriver.Write(personWithIdentifiers, "TEST_TRANS_ID"))
+}
+
+func TestMultipleFactsetIdentifiersAreWrittenAndRead(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithFactsetIdentifiers := minimalPerson
+	personWithFactsetIdentifiers.AlternativeIdentifiers.FactsetIdentifier = ""
+	personWithFactsetIdentifiers.AlternativeIdentifiers.FactsetIdentifiers = []string{fsIdentifier, "012345-F", "012345-G"}
+
+	assert.NoError(peopleDriver.Write(personWithFactsetIdentifiers, "TEST_TRANS_ID"), "Failed to write person")
+	readPeopleAndCompare(personWithFactsetIdentifiers, t, db)
+}
+
+func TestSingleFactsetIdentifierIsMergedWithTheList(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithFactsetIdentifiers := minimalPerson
+	personWithFactsetIdentifiers.AlternativeIdentifiers.FactsetIdentifiers = []string{"012345-F"}
+
+	assert.NoError(peopleDriver.Write(personWithFactsetIdentifiers, "TEST_TRANS_ID"), "Failed to write person")
+
+	p, found, err := peopleDriver.Read(minimalPersonUuid, "TEST_TRANS_ID")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal([]string{fsIdentifier, "012345-F"}, p.(person).AlternativeIdentifiers.FactsetIdentifiers)
+	assert.Empty(p.(person).AlternativeIdentifiers.FactsetIdentifier)
+}
+
+func TestSingleFactsetIdentifierIsReadFirstWhateverItsValue(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithFactsetIdentifiers := minimalPerson
+	personWithFactsetIdentifiers.AlternativeIdentifiers.FactsetIdentifier = "999999-Z"
+	personWithFactsetIdentifiers.AlternativeIdentifiers.FactsetIdentifiers = []string{"012345-F"}
+
+	assert.NoError(peopleDriver.Write(personWithFactsetIdentifiers, "TEST_TRANS_ID"), "Failed to write person")
+
+	p, found, err := peopleDriver.Read(minimalPersonUuid, "TEST_TRANS_ID")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("999999-Z", p.(person).AlternativeIdentifiers.asVersion1().FactsetIdentifier)
+}
+
+func TestWikidataAndSameAsAreWrittenReadAndLookedUp(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	linkedPerson := minimalPerson
+	linkedPerson.AlternativeIdentifiers.WikidataIdentifier = "Q42"
+	linkedPerson.AlternativeIdentifiers.SameAs = []string{"http://dbpedia.org/resource/Douglas_Adams"}
+
+	assert.NoError(peopleDriver.Write(linkedPerson, "TEST_TRANS_ID"), "Failed to write person")
+	readPeopleAndCompare(linkedPerson, t, db)
+
+	uuid, found, err := peopleDriver.Lookup(wikidataAuthority, "Q42")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(minimalPersonUuid, uuid)
+
+	uuid, found, err = peopleDriver.Lookup(sameAsAuthority, "http://dbpedia.org/resource/Douglas_Adams")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(minimalPersonUuid, uuid)
+
+	_, found, err = peopleDriver.Lookup(wikidataAuthority, "Q1")
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func TestImagesAreWrittenReadAndDeriveImageURL(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid, headshotImageUuid, bylineImageUuid}, db, t, assert)
+
+	personWithImages := minimalPerson
+	personWithImages.Images = []image{
+		{UUID: bylineImageUuid, Role: "byline", Variants: []imageVariant{{URL: "http://media.ft.com/byline.png"}}},
+		{UUID: headshotImageUuid, Role: headshotRole, Variants: []imageVariant{
+			{URL: "http://media.ft.com/headshot-square.png", Crop: "square", Width: 150, Height: 150},
+			{URL: "http://media.ft.com/headshot-wide.png", Crop: "wide", Width: 640, Height: 360},
+		}},
+	}
+
+	assert.NoError(peopleDriver.Write(personWithImages, "TEST_TRANS_ID"), "Failed to write person")
+
+	personWithImages.ImageURL = "http://media.ft.com/headshot-square.png"
+	readPeopleAndCompare(personWithImages, t, db)
+
+	personWithImages.Images = personWithImages.Images[1:]
+	assert.NoError(peopleDriver.Write(personWithImages, "TEST_TRANS_ID"), "Failed to write updated person")
+	readPeopleAndCompare(personWithImages, t, db)
+	assert.False(doesThingExistAtAll(bylineImageUuid, db, t, assert), "Image %s should have been removed with its last use", bylineImageUuid)
+
+	deleted, err := peopleDriver.Delete(minimalPersonUuid, "TEST_TRANS_ID")
+	assert.NoError(err)
+	assert.True(deleted)
+	assert.False(doesThingExistAtAll(minimalPersonUuid, db, t, assert), "Images should not keep person %s alive", minimalPersonUuid)
+	assert.False(doesThingExistAtAll(headshotImageUuid, db, t, assert), "Image %s should have been removed with the person", headshotImageUuid)
+}
+
+func TestImageWithoutRoleIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithImages := minimalPerson
+	personWithImages.Images = []image{{UUID: headshotImageUuid}}
+
+	err := peopleDriver.Write(personWithImages, "TEST_TRANS_ID")
+	assert.IsType(requestError{}, err)
+}
+
+func TestSocialProfilesAreNormalisedAndWritten(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithProfiles := minimalPerson
+	personWithProfiles.TwitterHandle = "https://twitter.com/rwa"
+	personWithProfiles.Profiles = []profile{
+		{Network: instagramNetwork, Handle: "rwa"},
+		{Network: mastodonNetwork, Handle: "rwa@mastodon.social"},
+	}
+
+	assert.NoError(peopleDriver.Write(personWithProfiles, "TEST_TRANS_ID"), "Failed to write person")
+
+	personWithProfiles.TwitterHandle = "@rwa"
+	personWithProfiles.Profiles = []profile{
+		{twitterNetwork, "@rwa", "https://twitter.com/rwa"},
+		{instagramNetwork, "@rwa", "https://www.instagram.com/rwa"},
+		{mastodonNetwork, "@rwa@mastodon.social", "https://mastodon.social/@rwa"},
+	}
+	readPeopleAndCompare(personWithProfiles, t, db)
+}
+
+func TestMalformedSocialProfileIsRejected(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithProfiles := minimalPerson
+	personWithProfiles.TwitterHandle = "https://facebook.com/rwa"
+
+	err := peopleDriver.Write(personWithProfiles, "TEST_TRANS_ID")
+	assert.IsType(requestError{}, err)
+}
+
+func TestDescriptionXMLIsSanitisedAndDescriptionDerived(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	personWithDescription := minimalPerson
+	personWithDescription.DescriptionXML = `<p><strong>Richer</strong> description<script>alert("x")</script></p>`
+
+	assert.NoError(peopleDriver.Write(personWithDescription, "TEST_TRANS_ID"), "Failed to write person")
+
+	personWithDescription.DescriptionXML = "<p><strong>Richer</strong> description</p>"
+	personWithDescription.Description = "Richer description"
+	readPeopleAndCompare(personWithDescription, t, db)
+}
+
+func TestMalformedDescriptionXMLIsRejectedOnWrite(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	personWithDescription := minimalPerson
+	personWithDescription.DescriptionXML = "<p>unclosed"
+
+	err := peopleDriver.Write(personWithDescription, "TEST_TRANS_ID")
+	assert.IsType(requestError{}, err)
+}
+
+func TestDuplicatesAreReported(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid, uniquePersonUuid}, db, t, assert)
+
+	first := minimalPerson
+	first.PrefLabel = "Robert Addington"
+	first.TwitterHandle = "@rwa"
+	assert.NoError(peopleDriver.Write(first, "TEST_TRANS_ID"), "Failed to write person")
+
+	second := person{
+		UUID:                   uniquePersonUuid,
+		PrefLabel:              "Robert W. Addington",
+		Aliases:                []string{"robert addington"},
+		TwitterHandle:          "https://twitter.com/RWA",
+		AlternativeIdentifiers: alternativeIdentifiers{UUIDS: []string{uniquePersonUuid}},
+	}
+	assert.NoError(peopleDriver.Write(second, "TEST_TRANS_ID"), "Failed to write person")
+
+	duplicates, err := peopleDriver.Duplicates(DefaultDuplicateConfidence)
+	assert.NoError(err)
+	assert.Len(duplicates, 1)
+	assert.Equal([]string{minimalPersonUuid, uniquePersonUuid}, duplicates[0].UUIDs)
+	assert.Equal(0.9, duplicates[0].Confidence)
+}
+
+func TestVerifyFindsAndRepairsIntegrityProblems(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid, uniquePersonUuid, fullPersonUuid, fullPersonSecondUuid}, db, t, assert)
+
+	broken := []*neoism.CypherQuery{
+		{Statement: `CREATE (:Identifier:TMEIdentifier {value:"orphanedTmeIdentifier"})`},
+		{Statement: `CREATE (:Thing:Person {uuid:{uuid}})`, Parameters: neoism.Props{"uuid": minimalPersonUuid}},
+		{Statement: `CREATE (:Thing {uuid:{uuid}})`, Parameters: neoism.Props{"uuid": uniquePersonUuid}},
+	}
+	assert.NoError(db.CypherBatch(broken))
+	assert.NoError(peopleDriver.Write(fullPerson, "TEST_TRANS_ID"), "Failed to write person")
+	// a person whose uuid is already one of the UPP identifiers of another
+	broken = []*neoism.CypherQuery{
+		{Statement: `CREATE (:Thing:Concept:Person {uuid:{uuid}})`, Parameters: neoism.Props{"uuid": fullPersonSecondUuid}},
+	}
+	assert.NoError(db.CypherBatch(broken))
+
+	problems, err := peopleDriver.Verify(false, DefaultRepairBatchSize)
+	assert.NoError(err)
+	found := make(map[string][]string)
+	for _, problem := range problems {
+		assert.Equal(0, problem.Repaired)
+		found[problem.Name] = problem.Examples
+	}
+	assert.Contains(found["orphanedIdentifiers"], "orphanedTmeIdentifier")
+	assert.Contains(found["missingUPPIdentifier"], minimalPersonUuid)
+	assert.Contains(found["bareThings"], uniquePersonUuid)
+	assert.Contains(found["personsWithoutConcept"], minimalPersonUuid)
+	assert.Contains(found["conflictingUPPIdentifier"], fullPersonSecondUuid)
+
+	_, err = peopleDriver.Verify(true, 1)
+	assert.NoError(err)
+
+	problems, err = peopleDriver.Verify(false, DefaultRepairBatchSize)
+	assert.NoError(err)
+	for _, problem := range problems {
+		if problem.Name == "conflictingUPPIdentifier" {
+			assert.Equal([]string{fullPersonSecondUuid}, problem.Examples, "The conflict should be left for someone to resolve")
+			continue
+		}
+		assert.Equal(0, problem.Count, problem.Name)
+	}
+}
+
+func TestSweeperRemovesOrphanedIdentifiers(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	assert.NoError(peopleDriver.Write(minimalPerson, "TEST_TRANS_ID"), "Failed to write person")
+	orphans := []*neoism.CypherQuery{
+		{Statement: `CREATE (:Identifier:TMEIdentifier {value:"orphanedTmeIdentifier"})`},
+		{Statement: `CREATE (:Identifier:TMEIdentifier {value:"secondOrphanedTmeIdentifier"})`},
+	}
+	assert.NoError(db.CypherBatch(orphans))
+
+	removed, err := NewIdentifierSweeper(peopleDriver, time.Hour, 1).Sweep()
+	assert.NoError(err)
+	assert.True(removed >= 2)
+	// identifiers of people are left alone
+	readPeopleAndCompare(minimalPerson, t, db)
+}
+
+func TestImpactAndCascadingDelete(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{fullPersonUuid, contentUUID, firstMembershipUuid, organisationUuid}, db, t, assert)
+
+	personWithMembership := fullPerson
+	personWithMembership.Memberships = []membership{{UUID: firstMembershipUuid, OrganisationUUID: organisationUuid}}
+	assert.NoError(peopleDriver.Write(personWithMembership, "TEST_TRANS_ID"), "Failed to write person")
+	writeContent(assert, db)
+	writeAnnotation(assert, db)
+
+	i, found, err := peopleDriver.Impact(fullPersonUuid, "")
+	assert.NoError(err)
+	assert.True(found)
+	assert.False(i.Removable)
+	assert.Contains(i.Relationships, relationshipCount{Type: "HAS_MEMBER", Direction: "incoming", Kind: membershipKind, Count: 1})
+	kinds := make(map[string]bool)
+	for _, r := range i.Relationships {
+		kinds[r.Kind] = true
+	}
+	assert.True(kinds[annotationKind], "Annotation should be listed in %v", i.Relationships)
+
+	_, err = peopleDriver.DeleteWithCascade(fullPersonUuid, "TEST_TRANS_ID", "annotations")
+	assert.IsType(requestError{}, err)
+
+	deleted, err := peopleDriver.DeleteWithCascade(fullPersonUuid, "TEST_TRANS_ID", cascadeMemberships)
+	assert.NoError(err)
+	assert.True(deleted)
+
+	memberships := []struct {
+		UUID string `json:"uuid"`
+	}{}
+	assert.NoError(db.CypherBatch([]*neoism.CypherQuery{{
+		Statement:  `MATCH (m:Membership {uuid:{uuid}}) RETURN m.uuid as uuid`,
+		Parameters: neoism.Props{"uuid": firstMembershipUuid},
+		Result:     &memberships,
+	}}))
+	assert.Empty(memberships, "Membership should have been removed with the person")
+
+	_, found, err = peopleDriver.Impact(fullPersonUuid, "")
+	assert.NoError(err)
+	assert.False(found)
+}
+
+func TestHealthChecksPassAfterInitialise(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	_, err := peopleDriver.CheckSchema()
+	assert.NoError(err)
+
+	assert.NoError(peopleDriver.Write(minimalPerson, "TEST_TRANS_ID"), "Failed to write person")
+	_, err = peopleDriver.CheckPeopleLoaded()
+	assert.NoError(err)
+
+	_, err = peopleDriver.CheckWriteLatency(time.Minute)
+	assert.NoError(err)
+	_, err = peopleDriver.CheckWriteLatency(0)
+	assert.Error(err)
+}
+
+func TestCancelledWritesAreNotMade(t *testing.T) {
+	assert := assert.New(t)
+	db := getDatabaseConnectionAndCheckClean(t, assert)
+	peopleDriver := getCypherDriver(db)
+
+	defer cleanDB([]string{minimalPersonUuid}, db, t, assert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(context.Canceled, peopleDriver.WriteContext(ctx, minimalPerson, "TEST_TRANS_ID"))
+
+	_, found, err := peopleDriver.ReadContext(context.Background(), minimalPersonUuid, "TEST_TRANS_ID")
+	assert.NoError(err)
+	assert.False(found)
+}
+
 func writeAnnotation(assert *assert.Assertions, db neoutils.NeoConnection) annotations.Service {
 	annotationsRW := annotations.NewCypherAnnotationsService(db, "v2", "annotations-v2")
 	assert.NoError(annotationsRW.Initialise())
@@ -373,7 +981,7 @@ func doesThingExistAtAll(uuid string, db neoutils.NeoConnection, t *testing.T, a
 func doesThingExistWithIdentifiers(uuid string, db neoutils.NeoConnection, t *testing.T, assert *assert.Assertions) bool {
 
 	result := []struct {
-		uuid string `json:"thing.uuid"`
+		uuid string
 	}{}
 
 	checkGraph := neoism.CypherQuery{
@@ -399,9 +1007,16 @@ func doesThingExistWithIdentifiers(uuid string, db neoutils.NeoConnection, t *te
 }
 
 func readPeopleAndCompare(expected person, t *testing.T, db neoutils.NeoConnection) {
+	// a single factsetIdentifier is always read back in factsetIdentifiers
+	expected.AlternativeIdentifiers = expected.AlternativeIdentifiers.normalised()
+	// as are profiles in their canonical form
+	expected, err := normaliseProfiles(expected)
+	assert.NoError(t, err)
 	sort.Strings(expected.Types)
 	sort.Strings(expected.AlternativeIdentifiers.TME)
 	sort.Strings(expected.AlternativeIdentifiers.UUIDS)
+	sort.Strings(expected.AlternativeIdentifiers.FactsetIdentifiers)
+	sortIdentifiers(expected.AlternativeIdentifiers.Identifiers)
 
 	actual, found, err := getCypherDriver(db).Read(expected.UUID, "TEST_TRANS_ID")
 	assert.NoError(t, err)
@@ -411,10 +1026,30 @@ func readPeopleAndCompare(expected person, t *testing.T, db neoutils.NeoConnecti
 	sort.Strings(actualPeople.Types)
 	sort.Strings(actualPeople.AlternativeIdentifiers.TME)
 	sort.Strings(actualPeople.AlternativeIdentifiers.UUIDS)
+	sort.Strings(actualPeople.AlternativeIdentifiers.FactsetIdentifiers)
+	sortIdentifiers(actualPeople.AlternativeIdentifiers.Identifiers)
 
 	assert.EqualValues(t, expected, actualPeople)
 }
 
+func readHash(uuid string, peopleDriver service, assert *assert.Assertions) string {
+	result := []struct {
+		Hash string `json:"hash"`
+	}{}
+
+	query := &neoism.CypherQuery{
+		Statement:  `MATCH (p:Person {uuid:{uuid}}) RETURN p.hash as hash`,
+		Parameters: neoism.Props{"uuid": uuid},
+		Result:     &result,
+	}
+
+	assert.NoError(peopleDriver.conn.CypherBatch([]*neoism.CypherQuery{query}))
+	if len(result) == 0 {
+		return ""
+	}
+	return result[0].Hash
+}
+
 func getDatabaseConnectionAndCheckClean(t *testing.T, assert *assert.Assertions) neoutils.NeoConnection {
 	db := getDatabaseConnection(assert)
 	checkDbClean([]string{fullPersonUuid, contentUUID, minimalPersonUuid, fullPersonSecondUuid, fullPersonThirdUuid, uniquePersonUuid}, db, t)
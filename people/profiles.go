@@ -0,0 +1,264 @@
+package people
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	twitterNetwork   = "twitter"
+	facebookNetwork  = "facebook"
+	linkedinNetwork  = "linkedin"
+	instagramNetwork = "instagram"
+	mastodonNetwork  = "mastodon"
+	websiteNetwork   = "website"
+)
+
+var (
+	twitterHandleRegex   = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	facebookHandleRegex  = regexp.MustCompile(`^[A-Za-z0-9.\-]{1,50}$`)
+	facebookIDRegex      = regexp.MustCompile(`^[0-9]{1,20}$`)
+	linkedinHandleRegex  = regexp.MustCompile(`^[A-Za-z0-9\-_]{3,100}$`)
+	instagramHandleRegex = regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`)
+	mastodonUserRegex    = regexp.MustCompile(`^[A-Za-z0-9_]{1,30}$`)
+	hostRegex            = regexp.MustCompile(`^[a-z0-9\-]+(\.[a-z0-9\-]+)+$`)
+)
+
+// profileNormalisers turn whatever was supplied for a network, a handle in any of its usual forms or a link to the
+// profile, into the canonical handle and url
+var profileNormalisers = map[string]func(string) (profile, error){
+	twitterNetwork:   normaliseTwitter,
+	facebookNetwork:  normaliseFacebook,
+	linkedinNetwork:  normaliseLinkedin,
+	instagramNetwork: normaliseInstagram,
+	mastodonNetwork:  normaliseMastodon,
+	websiteNetwork:   normaliseWebsite,
+}
+
+// normaliseProfiles canonicalises twitterHandle, facebookProfile and linkedinProfile, and returns them along with
+// any other profiles in profiles, each with a handle and url. A profile in the list for one of the older fields
+// fills that field when it is empty, for existing clients.
+func normaliseProfiles(p person) (person, error) {
+	var others []profile
+	for _, supplied := range p.Profiles {
+		normalise, found := profileNormalisers[supplied.Network]
+		if !found {
+			return p, fmt.Errorf("unknown network %q in profiles", supplied.Network)
+		}
+		// the url is preferred as a website has no handle of its own
+		value := supplied.URL
+		if value == "" {
+			value = supplied.Handle
+		}
+		normalised, err := normalise(value)
+		if err != nil {
+			return p, err
+		}
+		others = append(others, normalised)
+	}
+
+	legacy := []struct {
+		network string
+		field   *string
+	}{
+		{twitterNetwork, &p.TwitterHandle},
+		{facebookNetwork, &p.FacebookProfile},
+		{linkedinNetwork, &p.LinkedinProfile},
+	}
+
+	var profiles []profile
+	for _, l := range legacy {
+		if *l.field == "" {
+			for _, other := range others {
+				if other.Network == l.network {
+					*l.field = other.Handle
+					break
+				}
+			}
+		}
+		if *l.field == "" {
+			continue
+		}
+		normalised, err := profileNormalisers[l.network](*l.field)
+		if err != nil {
+			return p, err
+		}
+		*l.field = normalised.Handle
+		profiles = append(profiles, normalised)
+	}
+
+	for _, other := range others {
+		if !containsProfile(profiles, other) {
+			profiles = append(profiles, other)
+		}
+	}
+
+	p.Profiles = profiles
+	return p, nil
+}
+
+func containsProfile(profiles []profile, p profile) bool {
+	for _, existing := range profiles {
+		if existing.Network == p.Network && strings.EqualFold(existing.Handle, p.Handle) {
+			return true
+		}
+	}
+	return false
+}
+
+func normaliseTwitter(value string) (profile, error) {
+	handle, err := profileHandle(value, []string{"twitter.com", "x.com"}, "")
+	if err != nil {
+		return profile{}, err
+	}
+	handle = strings.TrimPrefix(handle, "@")
+	if !twitterHandleRegex.MatchString(handle) {
+		return profile{}, fmt.Errorf("%q is not a Twitter handle", value)
+	}
+	return profile{twitterNetwork, "@" + handle, "https://twitter.com/" + handle}, nil
+}
+
+// normaliseFacebook accepts a username, the numeric id of a profile without one, or a link to either. The link to a
+// profile without a username is profile.php?id=, which is not a username however much it looks like one.
+func normaliseFacebook(value string) (profile, error) {
+	handle, err := profileHandle(value, []string{"facebook.com", "fb.com"}, "")
+	if err != nil {
+		return profile{}, err
+	}
+	if handle == "profile.php" {
+		if u, isURL := parseProfileURL(value); isURL {
+			handle = u.Query().Get("id")
+		}
+		if !facebookIDRegex.MatchString(handle) {
+			return profile{}, fmt.Errorf("%q is not a link to a Facebook profile, it has no numeric id", value)
+		}
+	}
+	if facebookIDRegex.MatchString(handle) {
+		// usernames are never all digits
+		return profile{facebookNetwork, handle, "https://www.facebook.com/profile.php?id=" + handle}, nil
+	}
+	if !facebookHandleRegex.MatchString(handle) || strings.HasSuffix(handle, ".php") {
+		return profile{}, fmt.Errorf("%q is not a Facebook profile", value)
+	}
+	return profile{facebookNetwork, handle, "https://www.facebook.com/" + handle}, nil
+}
+
+func normaliseLinkedin(value string) (profile, error) {
+	handle, err := profileHandle(value, []string{"linkedin.com"}, "in")
+	if err != nil {
+		return profile{}, err
+	}
+	if !linkedinHandleRegex.MatchString(handle) {
+		return profile{}, fmt.Errorf("%q is not a LinkedIn profile", value)
+	}
+	return profile{linkedinNetwork, handle, "https://www.linkedin.com/in/" + handle}, nil
+}
+
+func normaliseInstagram(value string) (profile, error) {
+	handle, err := profileHandle(value, []string{"instagram.com"}, "")
+	if err != nil {
+		return profile{}, err
+	}
+	handle = strings.TrimPrefix(handle, "@")
+	if !instagramHandleRegex.MatchString(handle) {
+		return profile{}, fmt.Errorf("%q is not an Instagram handle", value)
+	}
+	return profile{instagramNetwork, "@" + handle, "https://www.instagram.com/" + handle}, nil
+}
+
+// normaliseMastodon accepts @user@instance, user@instance or a link to the profile on its instance
+func normaliseMastodon(value string) (profile, error) {
+	var user, host string
+	if u, isURL := parseProfileURL(value); isURL {
+		host = u.Host
+		user = strings.TrimPrefix(strings.Trim(u.Path, "/"), "@")
+	} else if parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(value), "@"), "@"); len(parts) == 2 {
+		user, host = parts[0], strings.ToLower(parts[1])
+	}
+	if !mastodonUserRegex.MatchString(user) || !hostRegex.MatchString(host) {
+		return profile{}, fmt.Errorf("%q is not a Mastodon account", value)
+	}
+	return profile{mastodonNetwork, "@" + user + "@" + host, "https://" + host + "/@" + user}, nil
+}
+
+func normaliseWebsite(value string) (profile, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !hostRegex.MatchString(strings.ToLower(u.Host)) {
+		return profile{}, fmt.Errorf("%q is not a website url", value)
+	}
+	u.Host = strings.ToLower(u.Host)
+	return profile{websiteNetwork, strings.TrimPrefix(u.Host, "www."), u.String()}, nil
+}
+
+// profileHandle returns the handle from value, which is either the handle itself or a link to the profile on one
+// of hosts, under pathPrefix if the network has one
+func profileHandle(value string, hosts []string, pathPrefix string) (string, error) {
+	value = strings.TrimSpace(value)
+	u, isURL := parseProfileURL(value)
+	if !isURL {
+		return value, nil
+	}
+
+	host := u.Host
+	for _, sub := range []string{"www.", "mobile.", "m."} {
+		host = strings.TrimPrefix(host, sub)
+	}
+	known := false
+	for _, h := range hosts {
+		if host == h {
+			known = true
+		}
+	}
+	if !known {
+		return "", fmt.Errorf("%q is not a link to a %s profile", value, strings.Join(hosts, " or "))
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if pathPrefix != "" {
+		if len(segments) < 2 || segments[0] != pathPrefix {
+			return "", fmt.Errorf("%q is not a link to a profile", value)
+		}
+		segments = segments[1:]
+	}
+	return segments[0], nil
+}
+
+// parseProfileURL parses value as a link if it looks like one, with or without the scheme
+func parseProfileURL(value string) (*url.URL, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "://") {
+		if slash := strings.Index(value, "/"); slash < 0 || !strings.Contains(value[:slash], ".") {
+			return nil, false
+		}
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+	u.Host = strings.ToLower(u.Host)
+	return u, true
+}
+
+// flattenProfiles turns profiles into three parallel lists, as Neo4j cannot store maps on a node
+func flattenProfiles(profiles []profile) (networks []string, handles []string, urls []string) {
+	for _, p := range profiles {
+		networks = append(networks, p.Network)
+		handles = append(handles, p.Handle)
+		urls = append(urls, p.URL)
+	}
+	return networks, handles, urls
+}
+
+func unflattenProfiles(networks []string, handles []string, urls []string) []profile {
+	if len(networks) != len(handles) || len(networks) != len(urls) {
+		return nil
+	}
+	var profiles []profile
+	for i, network := range networks {
+		profiles = append(profiles, profile{network, handles[i], urls[i]})
+	}
+	return profiles
+}
@@ -0,0 +1,95 @@
+package people
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwitterHandlesAreNormalised(t *testing.T) {
+	for _, value := range []string{"@rwa", "rwa", " rwa ", "https://twitter.com/rwa", "twitter.com/rwa/", "https://mobile.twitter.com/rwa", "https://x.com/rwa"} {
+		p, err := normaliseTwitter(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, profile{twitterNetwork, "@rwa", "https://twitter.com/rwa"}, p, value)
+	}
+}
+
+func TestMalformedProfilesAreRejected(t *testing.T) {
+	_, err := normaliseTwitter("not a handle")
+	assert.Error(t, err)
+	_, err = normaliseTwitter("https://facebook.com/rwa")
+	assert.Error(t, err)
+	_, err = normaliseLinkedin("https://www.linkedin.com/company/ft")
+	assert.Error(t, err)
+	_, err = normaliseMastodon("rwa")
+	assert.Error(t, err)
+	_, err = normaliseWebsite("ftp://example.com")
+	assert.Error(t, err)
+}
+
+func TestOtherNetworksAreNormalised(t *testing.T) {
+	p, err := normaliseFacebook("https://www.facebook.com/raddington")
+	assert.NoError(t, err)
+	assert.Equal(t, profile{facebookNetwork, "raddington", "https://www.facebook.com/raddington"}, p)
+
+	p, err = normaliseLinkedin("linkedin.com/in/robert-addington")
+	assert.NoError(t, err)
+	assert.Equal(t, profile{linkedinNetwork, "robert-addington", "https://www.linkedin.com/in/robert-addington"}, p)
+
+	p, err = normaliseInstagram("https://www.instagram.com/rwa/")
+	assert.NoError(t, err)
+	assert.Equal(t, profile{instagramNetwork, "@rwa", "https://www.instagram.com/rwa"}, p)
+
+	for _, value := range []string{"@rwa@mastodon.social", "rwa@Mastodon.social", "https://mastodon.social/@rwa"} {
+		p, err = normaliseMastodon(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, profile{mastodonNetwork, "@rwa@mastodon.social", "https://mastodon.social/@rwa"}, p, value)
+	}
+
+	p, err = normaliseWebsite("https://WWW.Example.com/about")
+	assert.NoError(t, err)
+	assert.Equal(t, profile{websiteNetwork, "example.com", "https://www.example.com/about"}, p)
+}
+
+func TestFacebookProfilesWithoutAUsernameAreNormalisedToTheirID(t *testing.T) {
+	for _, value := range []string{"123456789", "https://www.facebook.com/profile.php?id=123456789", "facebook.com/profile.php?id=123456789&ref=bookmarks", "https://m.facebook.com/123456789"} {
+		p, err := normaliseFacebook(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, profile{facebookNetwork, "123456789", "https://www.facebook.com/profile.php?id=123456789"}, p, value)
+	}
+
+	for _, value := range []string{"https://www.facebook.com/profile.php", "https://www.facebook.com/profile.php?id=raddington", "profile.php", "https://www.facebook.com/home.php"} {
+		_, err := normaliseFacebook(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestNormaliseProfilesMergesOlderFields(t *testing.T) {
+	p := person{
+		TwitterHandle: "https://twitter.com/rwa",
+		Profiles: []profile{
+			{Network: linkedinNetwork, URL: "https://www.linkedin.com/in/robert-addington"},
+			{Network: twitterNetwork, Handle: "RWA"},
+			{Network: websiteNetwork, URL: "https://example.com"},
+		},
+	}
+
+	normalised, err := normaliseProfiles(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "@rwa", normalised.TwitterHandle)
+	assert.Equal(t, "robert-addington", normalised.LinkedinProfile)
+	assert.Equal(t, []profile{
+		{twitterNetwork, "@rwa", "https://twitter.com/rwa"},
+		{linkedinNetwork, "robert-addington", "https://www.linkedin.com/in/robert-addington"},
+		{websiteNetwork, "example.com", "https://example.com"},
+	}, normalised.Profiles)
+
+	again, err := normaliseProfiles(normalised)
+	assert.NoError(t, err)
+	assert.Equal(t, normalised, again)
+}
+
+func TestUnknownNetworkIsRejected(t *testing.T) {
+	_, err := normaliseProfiles(person{Profiles: []profile{{Network: "myspace", Handle: "rwa"}}})
+	assert.Error(t, err)
+}
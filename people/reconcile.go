@@ -0,0 +1,273 @@
+package people
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/jmcvetta/neoism"
+)
+
+// reconcileRunSize is how many source entries are sorted in memory at a time. Larger sources are sorted in runs of
+// this size, which are written to temporary files and merged back together.
+const reconcileRunSize = 100000
+
+// reconciliation lists the differences between an authoritative source of uuids and hashes and what is in Neo4j
+type reconciliation struct {
+	Missing []string `json:"missing"`
+	Changed []string `json:"changed"`
+	Extra   []string `json:"extra"`
+}
+
+// Reconcile compares a source of uuids and hashes with every Person in Neo4j, reporting people missing from Neo4j,
+// people whose stored hash differs from the source, and people in Neo4j the source knows nothing about. The source
+// is read one entry at a time by next, which returns false once there are no more. It is sorted by uuid on disk, so
+// that it can be walked alongside the people in Neo4j without holding either in memory. A uuid given more than once
+// is rejected.
+func (s service) Reconcile(ctx context.Context, next func() (rwapi.IDEntry, bool, error)) (reconciliation, error) {
+	r := reconciliation{
+		Missing: []string{},
+		Changed: []string{},
+		Extra:   []string{},
+	}
+
+	sorted, cleanup, err := sortSource(next, reconcileRunSize)
+	if err != nil {
+		return reconciliation{}, err
+	}
+	defer cleanup()
+
+	previous := ""
+	nextSource := func() (rwapi.IDEntry, bool, error) {
+		id, more, err := sorted()
+		if err != nil || !more {
+			return id, more, err
+		}
+		if id.ID == previous {
+			return id, false, requestError{fmt.Sprintf("Source lists %s more than once", id.ID)}
+		}
+		previous = id.ID
+		return id, true, nil
+	}
+	nextStored := s.storedIDsInOrder(ctx)
+
+	source, sourceMore, err := nextSource()
+	if err != nil {
+		return reconciliation{}, err
+	}
+	stored, storedMore, err := nextStored()
+	if err != nil {
+		return reconciliation{}, err
+	}
+
+	for sourceMore || storedMore {
+		switch {
+		case !storedMore || (sourceMore && source.ID < stored.ID):
+			r.Missing = append(r.Missing, source.ID)
+			source, sourceMore, err = nextSource()
+		case !sourceMore || stored.ID < source.ID:
+			r.Extra = append(r.Extra, stored.ID)
+			stored, storedMore, err = nextStored()
+		default:
+			if source.Hash != stored.Hash {
+				r.Changed = append(r.Changed, source.ID)
+			}
+			if source, sourceMore, err = nextSource(); err == nil {
+				stored, storedMore, err = nextStored()
+			}
+		}
+		if err != nil {
+			return reconciliation{}, err
+		}
+	}
+
+	return r, nil
+}
+
+// storedIDsInOrder returns a function giving the uuid and hash of every Person in Neo4j in uuid order, a page at a
+// time. Each page starts after the last uuid of the one before rather than skipping, so it can use the uuid index.
+func (s service) storedIDsInOrder(ctx context.Context) func() (rwapi.IDEntry, bool, error) {
+	batchSize := 4096
+	page := []rwapi.IDEntry{}
+	after := ""
+	done := false
+
+	return func() (rwapi.IDEntry, bool, error) {
+		if len(page) == 0 && !done {
+			readQuery := &neoism.CypherQuery{
+				Statement: `MATCH (p:Person) WHERE p.uuid > {after}
+						RETURN p.uuid as id, p.hash as hash ORDER BY p.uuid LIMIT {limit}`,
+				Parameters: map[string]interface{}{
+					"after": after,
+					"limit": batchSize,
+				},
+				Result: &page,
+			}
+			pageCtx, cancel := withTimeout(ctx, s.timeouts.IDs)
+			err := s.cypherBatchContext(pageCtx, "", "reconcile", []*neoism.CypherQuery{readQuery})
+			cancel()
+			if err != nil {
+				return rwapi.IDEntry{}, false, err
+			}
+			done = len(page) < batchSize
+		}
+		if len(page) == 0 {
+			return rwapi.IDEntry{}, false, nil
+		}
+		id := page[0]
+		page = page[1:]
+		after = id.ID
+		return id, true, nil
+	}
+}
+
+type idEntries []rwapi.IDEntry
+
+func (ids idEntries) Len() int           { return len(ids) }
+func (ids idEntries) Less(i, j int) bool { return ids[i].ID < ids[j].ID }
+func (ids idEntries) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+
+// sortSource reads every entry from next, and returns a function giving them back in id order. At most runSize
+// entries are held in memory: a source larger than that is sorted in runs written to temporary files, which are
+// merged as they are read back. cleanup removes the files, and must be called once the entries have been read.
+func sortSource(next func() (rwapi.IDEntry, bool, error), runSize int) (sorted func() (rwapi.IDEntry, bool, error), cleanup func(), err error) {
+	var runs runHeap
+	cleanup = func() {
+		for _, r := range runs {
+			r.close()
+		}
+	}
+
+	run := idEntries{}
+	for {
+		id, more, err := next()
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		if more {
+			run = append(run, id)
+		}
+		if len(run) == runSize || (!more && len(runs) > 0 && len(run) > 0) {
+			r, err := spillRun(run)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			runs = append(runs, r)
+			run = idEntries{}
+		}
+		if !more {
+			break
+		}
+	}
+
+	if len(runs) == 0 {
+		sort.Sort(run)
+		return func() (rwapi.IDEntry, bool, error) {
+			if len(run) == 0 {
+				return rwapi.IDEntry{}, false, nil
+			}
+			id := run[0]
+			run = run[1:]
+			return id, true, nil
+		}, func() {}, nil
+	}
+
+	merging := runHeap{}
+	for _, r := range runs {
+		if err := r.advance(); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		if r.more {
+			merging = append(merging, r)
+		}
+	}
+	heap.Init(&merging)
+
+	return func() (rwapi.IDEntry, bool, error) {
+		if len(merging) == 0 {
+			return rwapi.IDEntry{}, false, nil
+		}
+		r := merging[0]
+		id := r.head
+		if err := r.advance(); err != nil {
+			return id, false, err
+		}
+		if r.more {
+			heap.Fix(&merging, 0)
+		} else {
+			heap.Pop(&merging)
+		}
+		return id, true, nil
+	}, cleanup, nil
+}
+
+// sortedRun is a sorted run of source entries in a temporary file, being read back one entry at a time
+type sortedRun struct {
+	f    *os.File
+	dec  *json.Decoder
+	head rwapi.IDEntry
+	more bool
+}
+
+func spillRun(run idEntries) (*sortedRun, error) {
+	sort.Sort(run)
+	f, err := ioutil.TempFile("", "people-reconcile")
+	if err != nil {
+		return nil, err
+	}
+	r := &sortedRun{f: f}
+
+	enc := json.NewEncoder(f)
+	for _, id := range run {
+		if err := enc.Encode(id); err != nil {
+			r.close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.dec = json.NewDecoder(f)
+	return r, nil
+}
+
+// advance reads the next entry of the run into head, setting more to false at the end of the run
+func (r *sortedRun) advance() error {
+	r.head = rwapi.IDEntry{}
+	err := r.dec.Decode(&r.head)
+	if err == io.EOF {
+		r.more = false
+		return nil
+	}
+	r.more = err == nil
+	return err
+}
+
+func (r *sortedRun) close() {
+	r.f.Close()
+	os.Remove(r.f.Name())
+}
+
+// runHeap orders the runs being merged by the entry each is at
+type runHeap []*sortedRun
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].head.ID < h[j].head.ID }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*sortedRun)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
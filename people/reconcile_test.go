@@ -0,0 +1,86 @@
+package people
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func entries(ids ...string) func() (rwapi.IDEntry, bool, error) {
+	return func() (rwapi.IDEntry, bool, error) {
+		if len(ids) == 0 {
+			return rwapi.IDEntry{}, false, nil
+		}
+		id := ids[0]
+		ids = ids[1:]
+		return rwapi.IDEntry{ID: id, Hash: "hash-" + id}, true, nil
+	}
+}
+
+func readAll(t *testing.T, next func() (rwapi.IDEntry, bool, error)) []string {
+	ids := []string{}
+	for {
+		id, more, err := next()
+		assert.NoError(t, err)
+		if !more {
+			return ids
+		}
+		assert.Equal(t, "hash-"+id.ID, id.Hash)
+		ids = append(ids, id.ID)
+	}
+}
+
+func spilledRuns() []string {
+	files, _ := filepath.Glob(filepath.Join(os.TempDir(), "people-reconcile*"))
+	return files
+}
+
+func TestSmallSourcesAreSortedInMemory(t *testing.T) {
+	before := spilledRuns()
+	sorted, cleanup, err := sortSource(entries("c", "a", "b"), 10)
+	assert.NoError(t, err)
+	defer cleanup()
+
+	assert.Equal(t, before, spilledRuns())
+	assert.Equal(t, []string{"a", "b", "c"}, readAll(t, sorted))
+}
+
+func TestLargeSourcesAreSortedInRunsOnDisk(t *testing.T) {
+	before := spilledRuns()
+	sorted, cleanup, err := sortSource(entries("g", "c", "e", "a", "f", "b", "d"), 3)
+	assert.NoError(t, err)
+
+	assert.Len(t, spilledRuns(), len(before)+3, "Three runs should be spilled")
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g"}, readAll(t, sorted))
+
+	cleanup()
+	assert.Equal(t, before, spilledRuns(), "The runs should be removed")
+}
+
+func TestDuplicatesSurviveSorting(t *testing.T) {
+	sorted, cleanup, err := sortSource(entries("b", "a", "b", "c"), 2)
+	assert.NoError(t, err)
+	defer cleanup()
+
+	assert.Equal(t, []string{"a", "b", "b", "c"}, readAll(t, sorted))
+}
+
+func TestSourceErrorsStopSorting(t *testing.T) {
+	before := spilledRuns()
+	failed := errors.New("bad request body")
+	read := 0
+	next := func() (rwapi.IDEntry, bool, error) {
+		if read++; read > 3 {
+			return rwapi.IDEntry{}, false, failed
+		}
+		return rwapi.IDEntry{ID: "a"}, true, nil
+	}
+
+	_, _, err := sortSource(next, 2)
+	assert.Equal(t, failed, err)
+	assert.Equal(t, before, spilledRuns(), "Runs spilled before the error should be removed")
+}
@@ -0,0 +1,114 @@
+package people
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	log "github.com/Sirupsen/logrus"
+)
+
+// RetryPolicy is how writes and deletes are retried when Neo4j fails with an error which may not happen again, such
+// as a deadlock between concurrent batches or a cluster changing leader. Each wait is a random time up to Backoff,
+// which doubles after every attempt up to MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy rides out a leader election, which takes a few seconds
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:   5,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+	}
+}
+
+// WithRetryPolicy returns the service retrying writes and deletes by policy instead of the default
+func (s service) WithRetryPolicy(policy RetryPolicy) service {
+	s.retries = policy
+	return s
+}
+
+// transientCodes are the status codes Neo4j gives outside its TransientError class for errors which are worth
+// retrying, as a cluster which is changing leader turns writes away until it has a new one
+var transientCodes = map[string]bool{
+	"Neo.ClientError.Cluster.NotALeader":                  true,
+	"Neo.ClientError.General.ForbiddenOnReadOnlyDatabase": true,
+}
+
+// isTransient reports whether the error could go away by itself, so the batch is worth running again. A failed
+// transaction is judged by the status codes Neo4j gave for it, so constraint violations never are.
+func isTransient(err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+
+	switch e := err.(type) {
+	case notConnectedError:
+		return true
+	case rwapi.ConstraintOrTransactionError:
+		for _, code := range neo4jErrorCodes(e) {
+			if strings.HasPrefix(code, "Neo.TransientError.") || transientCodes[code] {
+				return true
+			}
+		}
+		return false
+	case net.Error:
+		// only a batch which never reached Neo4j is sure to have left nothing behind. One which timed out or lost its
+		// connection may still have been committed, so running it again could fail, e.g. with a 404 for a delete.
+		return isDialError(e)
+	default:
+		return isDialError(err)
+	}
+}
+
+// isDialError reports whether the error is from failing to connect to Neo4j
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryTransient calls f until it succeeds, fails with an error that is not transient, or runs out of attempts. A
+// failed attempt leaves nothing behind, but not only because each batch is run in a transaction: the batch runner
+// merges the batches of concurrent callers into one transaction, so every one of them fails with the same error,
+// rolled back together, including for another caller's constraint violation. A transient error is therefore retried
+// by each caller on its own, and a caller can be failed by a batch which was not its own.
+func (s service) retryTransient(ctx context.Context, operation string, transactionId string, f func() error) error {
+	backoff := s.retries.Backoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if !isTransient(err) {
+			return err
+		}
+		if attempt >= s.retries.Attempts {
+			retriesExhausted.WithLabelValues(operation).Inc()
+			return err
+		}
+
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		transactionLogger(transactionId).WithFields(log.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"wait":      wait.String(),
+		}).WithError(err).Warn("Transient Neo4j error, retrying")
+		retries.WithLabelValues(operation).Inc()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > s.retries.MaxBackoff {
+			backoff = s.retries.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package people
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/up-rw-app-api-go/rwapi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransientErrorsAreRecognised(t *testing.T) {
+	deadlock := rwapi.ConstraintOrTransactionError{
+		Message: "Transaction failed",
+		Details: []string{"Neo.TransientError.Transaction.DeadlockDetected: ForsetiClient[3] can't acquire ExclusiveLock{owner=ForsetiClient[5]} on NODE(12), because holders of that lock are waiting for ForsetiClient[3]. Wait list:ExclusiveLock[Client[5] waits for [3]] (DeadlockDetected)"},
+	}
+	leaderSwitch := rwapi.ConstraintOrTransactionError{
+		Message: "Transaction failed",
+		Details: []string{"Neo.ClientError.Cluster.NotALeader: No write operations are allowed directly on this database. Writes must pass through the leader."},
+	}
+	unreachable := &url.Error{Op: "Post", URL: "http://localhost:7474/db/data/transaction", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	constraint := rwapi.ConstraintOrTransactionError{
+		Message: "Transaction failed",
+		Details: []string{"Neo.ClientError.Schema.ConstraintValidationFailed: Node(4) already exists with label `TMEIdentifier` and property `value` = 'Neo.TransientError'"},
+	}
+	uncoded := rwapi.ConstraintOrTransactionError{Message: "Transaction failed", Details: []string{"DeadlockDetected"}}
+
+	assert.True(t, isTransient(deadlock))
+	assert.True(t, isTransient(leaderSwitch))
+	assert.True(t, isTransient(unreachable))
+	assert.True(t, isTransient(notConnectedError{errors.New("connection refused")}))
+	assert.False(t, isTransient(constraint))
+	assert.False(t, isTransient(uncoded), "only the codes are trusted, not the messages")
+	assert.False(t, isTransient(requestError{"bad"}))
+	assert.False(t, isTransient(context.DeadlineExceeded))
+	assert.False(t, isTransient(nil))
+}
+
+func TestRequestsWhichMayHaveReachedNeo4jAreNotRetried(t *testing.T) {
+	timedOut := &url.Error{Op: "Post", URL: "http://localhost:7474/db/data/transaction", Err: &net.OpError{Op: "read", Err: timeoutError{}}}
+	reset := &url.Error{Op: "Post", URL: "http://localhost:7474/db/data/transaction", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}
+
+	assert.False(t, isTransient(timedOut))
+	assert.False(t, isTransient(reset))
+	assert.True(t, isNeo4jFailure(timedOut), "the breaker should still count Neo4j being too slow")
+	assert.True(t, isNeo4jFailure(reset))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var deadlockError = rwapi.ConstraintOrTransactionError{Message: "Transaction failed", Details: []string{"Neo.TransientError.Transaction.DeadlockDetected: deadlock"}}
+
+func retryingService(attempts int) service {
+	return NewCypherPeopleService(stubConnection{}).WithRetryPolicy(RetryPolicy{Attempts: attempts, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+}
+
+func TestTransientErrorsAreRetried(t *testing.T) {
+	before := testutil.ToFloat64(retries.WithLabelValues("write"))
+	calls := 0
+	err := retryingService(5).retryTransient(context.Background(), "write", "tid_test", func() error {
+		calls++
+		if calls < 3 {
+			return deadlockError
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, before+2, testutil.ToFloat64(retries.WithLabelValues("write")))
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	calls := 0
+	err := retryingService(5).retryTransient(context.Background(), "write", "tid_test", func() error {
+		calls++
+		return rwapi.ConstraintOrTransactionError{Message: "Transaction failed", Details: []string{"Neo.ClientError.Schema.ConstraintValidationFailed: Node(4) already exists with label `Person`"}}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetriesRunOut(t *testing.T) {
+	before := testutil.ToFloat64(retriesExhausted.WithLabelValues("delete"))
+	calls := 0
+	err := retryingService(3).retryTransient(context.Background(), "delete", "tid_test", func() error {
+		calls++
+		return deadlockError
+	})
+
+	assert.True(t, isTransient(err))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, before+1, testutil.ToFloat64(retriesExhausted.WithLabelValues("delete")))
+}
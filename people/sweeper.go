@@ -0,0 +1,78 @@
+package people
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rcrowley/go-metrics"
+)
+
+// IdentifierSweeper regularly removes Identifier nodes which no longer identify anything, as left by batches which
+// were aborted part way through. Left alone they trip the uniqueness constraints when the identifier is next written.
+type IdentifierSweeper struct {
+	service   service
+	interval  time.Duration
+	batchSize int
+	stop      chan struct{}
+	done      chan struct{}
+
+	removed     metrics.Counter
+	lastRemoved metrics.Gauge
+	failures    metrics.Counter
+}
+
+// NewIdentifierSweeper returns a sweeper removing at most batchSize identifiers per transaction every interval,
+// reporting to the default metrics registry, which is sent to Graphite, as well as to MetricsHandler
+func NewIdentifierSweeper(s service, interval time.Duration, batchSize int) *IdentifierSweeper {
+	return &IdentifierSweeper{
+		service:     s,
+		interval:    interval,
+		batchSize:   batchSize,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		removed:     metrics.GetOrRegisterCounter("identifierSweeper.removed", metrics.DefaultRegistry),
+		lastRemoved: metrics.GetOrRegisterGauge("identifierSweeper.lastRemoved", metrics.DefaultRegistry),
+		failures:    metrics.GetOrRegisterCounter("identifierSweeper.failures", metrics.DefaultRegistry),
+	}
+}
+
+// Run sweeps every interval until Stop is called
+func (sw *IdentifierSweeper) Run() {
+	defer close(sw.done)
+	ticker := time.NewTicker(sw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sw.Sweep()
+		case <-sw.stop:
+			return
+		}
+	}
+}
+
+// Stop ends Run, waiting for a sweep in progress to finish
+func (sw *IdentifierSweeper) Stop() {
+	close(sw.stop)
+	<-sw.done
+}
+
+// Sweep removes every orphaned Identifier now, returning how many there were
+func (sw *IdentifierSweeper) Sweep() (int, error) {
+	removed, err := sw.service.repair(orphanedIdentifiersCheck, sw.batchSize)
+	sw.removed.Inc(int64(removed))
+	sw.lastRemoved.Update(int64(removed))
+	sweptIdentifiers.Add(float64(removed))
+	lastSweptIdentifiers.Set(float64(removed))
+	if err != nil {
+		sw.failures.Inc(1)
+		sweepFailures.Inc()
+		log.Errorf("Identifier sweep failed after removing %d orphaned identifiers, error=[%s]", removed, err)
+		return removed, err
+	}
+	if removed > 0 {
+		log.Infof("Removed %d orphaned identifiers", removed)
+	}
+	return removed, nil
+}
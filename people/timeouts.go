@@ -0,0 +1,122 @@
+package people
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/Financial-Times/neo-utils-go/neoutils"
+	"github.com/jmcvetta/neoism"
+)
+
+// Timeouts limit how long each operation waits for Neo4j. A caller's own deadline still applies when it is sooner,
+// and zero means no limit.
+type Timeouts struct {
+	Read   time.Duration
+	Write  time.Duration
+	Delete time.Duration
+	IDs    time.Duration
+	Count  time.Duration
+}
+
+// DefaultTimeouts are generous enough for a busy Neo4j, but stop a hung one holding on to requests forever. IDs
+// applies to each page of ids rather than the whole stream.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Read:   10 * time.Second,
+		Write:  30 * time.Second,
+		Delete: 30 * time.Second,
+		IDs:    time.Minute,
+		Count:  10 * time.Second,
+	}
+}
+
+// WithTimeouts returns the service with its operations limited by timeouts instead of the defaults
+func (s service) WithTimeouts(timeouts Timeouts) service {
+	s.timeouts = timeouts
+	return s
+}
+
+// Longest is the longest any operation with a limit waits for Neo4j, or 0 if none of them has one
+func (t Timeouts) Longest() time.Duration {
+	var longest time.Duration
+	for _, timeout := range []time.Duration{t.Read, t.Write, t.Delete, t.IDs, t.Count} {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	return longest
+}
+
+// fallbackClientTimeout limits batches when neither the HTTP client nor any operation has a limit of its own
+const fallbackClientTimeout = time.Minute
+
+// ClientTimeout is how long the HTTP client of the connection to Neo4j should wait for a batch, given its default.
+// It is never less than the default or Longest, so no operation is cut short by the client, and is never 0, so that
+// a batch abandoned by an operation without a limit does not hold on to a connection forever.
+func (t Timeouts) ClientTimeout(clientDefault time.Duration) time.Duration {
+	timeout := clientDefault
+	if longest := t.Longest(); longest > timeout {
+		timeout = longest
+	}
+	if timeout <= 0 {
+		return fallbackClientTimeout
+	}
+	return timeout
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// runCypherBatch runs the queries until the context is done. The batch cannot be interrupted once it has been sent,
+// so it is left to finish, or be rolled back, by Neo4j while the caller gets the context's error straight away.
+// The batch runs on copies of the queries, with results of their own, which are only copied back to the caller's
+// queries when it finishes in time, so an abandoned batch never writes to anything the caller still holds. The
+// connection's HTTP client should time out after Timeouts.ClientTimeout, so that an abandoned batch does not hold on
+// to a connection, and this goroutine, forever.
+func runCypherBatch(ctx context.Context, conn neoutils.NeoConnection, queries []*neoism.CypherQuery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return conn.CypherBatch(queries)
+	}
+
+	copies := make([]*neoism.CypherQuery, len(queries))
+	for i, q := range queries {
+		c := *q
+		if q.Result != nil {
+			c.Result = reflect.New(reflect.TypeOf(q.Result).Elem()).Interface()
+		}
+		copies[i] = &c
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.CypherBatch(copies)
+	}()
+
+	select {
+	case err := <-done:
+		for i, q := range queries {
+			result := q.Result
+			// along with the results, this copies the stats of queries which asked for them
+			*q = *copies[i]
+			if result != nil {
+				reflect.ValueOf(result).Elem().Set(reflect.ValueOf(copies[i].Result).Elem())
+			}
+			q.Result = result
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isContextError(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
@@ -0,0 +1,133 @@
+package people
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+)
+
+// hungConnection never finishes a batch, like a Neo4j which has stopped responding
+type hungConnection struct {
+	stubConnection
+	started chan struct{}
+}
+
+func (c hungConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	close(c.started)
+	select {}
+}
+
+func TestHungNeo4jTimesOut(t *testing.T) {
+	s := NewCypherPeopleService(hungConnection{started: make(chan struct{})}).WithTimeouts(Timeouts{Count: 10 * time.Millisecond})
+
+	_, err := s.Count()
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCancelledRequestsGiveUp(t *testing.T) {
+	conn := hungConnection{started: make(chan struct{})}
+	s := NewCypherPeopleService(conn).WithTimeouts(Timeouts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-conn.started
+		cancel()
+	}()
+
+	_, _, err := s.ReadContext(ctx, "uuid", "tid_test")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestNothingIsRunWhenTheContextIsAlreadyDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runCypherBatch(ctx, hungConnection{started: make(chan struct{})}, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// lateConnection answers a batch only once it is told to, after the caller has given up on it
+type lateConnection struct {
+	stubConnection
+	started  chan struct{}
+	answer   chan struct{}
+	answered chan struct{}
+}
+
+func (c lateConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	close(c.started)
+	<-c.answer
+	for _, q := range queries {
+		*(q.Result.(*[]string)) = []string{"late"}
+	}
+	close(c.answered)
+	return nil
+}
+
+func TestAbandonedBatchesDoNotWriteToTheCallersResults(t *testing.T) {
+	conn := lateConnection{started: make(chan struct{}), answer: make(chan struct{}), answered: make(chan struct{})}
+	results := []string{}
+	query := &neoism.CypherQuery{Statement: "MATCH (n) RETURN n", Result: &results}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-conn.started
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, runCypherBatch(ctx, conn, []*neoism.CypherQuery{query}))
+
+	close(conn.answer)
+	<-conn.answered
+	assert.Empty(t, results)
+	assert.Equal(t, &results, query.Result)
+}
+
+func TestBatchesFinishingInTimeFillInTheCallersResults(t *testing.T) {
+	conn := lateConnection{started: make(chan struct{}), answer: make(chan struct{}), answered: make(chan struct{})}
+	close(conn.answer)
+	results := []string{}
+	query := &neoism.CypherQuery{Statement: "MATCH (n) RETURN n", Result: &results}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, runCypherBatch(ctx, conn, []*neoism.CypherQuery{query}))
+	assert.Equal(t, []string{"late"}, results)
+	assert.Equal(t, &results, query.Result)
+}
+
+func TestTimeoutsAreGatewayTimeouts(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeServiceError(w, context.DeadlineExceeded)
+	assert.Equal(t, 504, w.Code)
+}
+
+func TestCancelledRequestsAreNotServiceFailures(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeServiceError(w, context.Canceled)
+	assert.Equal(t, 499, w.Code)
+}
+
+func TestLongestTimeout(t *testing.T) {
+	assert.Equal(t, time.Minute, DefaultTimeouts().Longest())
+
+	unlimited := DefaultTimeouts()
+	unlimited.IDs = 0
+	assert.Equal(t, 30*time.Second, unlimited.Longest(), "operations without a limit should be left out")
+	assert.Equal(t, time.Duration(0), Timeouts{}.Longest())
+}
+
+func TestClientTimeoutIsNeverShorterThanItsDefaultNorOff(t *testing.T) {
+	assert.Equal(t, time.Minute, DefaultTimeouts().ClientTimeout(time.Minute))
+	assert.Equal(t, 2*time.Minute, DefaultTimeouts().ClientTimeout(2*time.Minute))
+
+	long := DefaultTimeouts()
+	long.Write = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, long.ClientTimeout(time.Minute))
+
+	assert.Equal(t, time.Minute, Timeouts{}.ClientTimeout(time.Minute), "operations without a limit should not turn off the client's")
+	assert.Equal(t, fallbackClientTimeout, Timeouts{}.ClientTimeout(0))
+}
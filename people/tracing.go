@@ -0,0 +1,209 @@
+package people
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/transactionid-utils-go"
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmcvetta/neoism"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/Financial-Times/people-rw-neo4j/people"
+
+	maxSpansPerExport   = 512
+	spanExportInterval  = 5 * time.Second
+	spanExportQueueSize = 4096
+)
+
+// transactionIDAttribute links spans to the logs for the same request
+const transactionIDAttribute = attribute.Key("ft.transaction_id")
+
+// traceContext reads and writes W3C traceparent headers
+var traceContext = propagation.TraceContext{}
+
+// tracerProvider is set by EnableOTLPTracing, and until then spans come from the global provider, which does not
+// record them
+var tracerProvider *sdktrace.TracerProvider
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// traced wraps a handler in a server span, named after its route rather than the path so spans can be grouped. The
+// span continues the trace in the request's traceparent header, if it has one, and is carried in the request's
+// context to be the parent of the spans for the Cypher batches run for it.
+func traced(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, sp := tracer().Start(ctx, route, trace.WithSpanKind(trace.SpanKindServer))
+		defer sp.End()
+
+		r = r.WithContext(ctx)
+		tid := transactionidutils.GetTransactionIDFromRequest(r)
+		if r.Header.Get(transactionidutils.TransactionIDHeader) == "" {
+			// so the handler passes the same transaction id to the service as the span is tagged with, without
+			// changing the headers of the request it was given
+			r.Header = r.Header.Clone()
+			r.Header.Set(transactionidutils.TransactionIDHeader, tid)
+		}
+		sp.SetAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			transactionIDAttribute.String(tid),
+		)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(rec, r)
+
+		sp.SetAttributes(semconv.HTTPStatusCodeKey.Int(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			sp.SetStatus(codes.Error, fmt.Sprintf("%d %s", rec.status, http.StatusText(rec.status)))
+		}
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// cypherBatch runs the queries in a client span named after what they do, recording how many rows they returned,
+// and logs how long they took against the transaction id. The span starts a new trace, as there is no request to
+// be part of.
+func (s service) cypherBatch(transactionID string, name string, queries []*neoism.CypherQuery) error {
+	return s.cypherBatchContext(context.Background(), transactionID, name, queries)
+}
+
+// cypherBatchContext is cypherBatch giving up when the context is done, with the span a child of any in the
+// context. Nothing is sent while the circuit breaker is open.
+func (s service) cypherBatchContext(ctx context.Context, transactionID string, name string, queries []*neoism.CypherQuery) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+
+	_, sp := tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNeo4j,
+		semconv.DBOperationKey.String(name),
+		attribute.Int("db.statement_count", len(queries)),
+	))
+	if transactionID != "" {
+		sp.SetAttributes(transactionIDAttribute.String(transactionID))
+	}
+
+	start := time.Now()
+	err := runCypherBatch(ctx, s.conn, queries)
+	s.breaker.record(err)
+	// an abandoned batch has results of its own, so nothing is counted for it
+	rows := rowCount(queries)
+
+	sp.SetAttributes(attribute.Int("db.row_count", rows))
+	if err != nil {
+		sp.RecordError(err)
+		sp.SetStatus(codes.Error, err.Error())
+	}
+	sp.End()
+
+	entry := transactionLogger(transactionID).WithFields(log.Fields{
+		"operation":  name,
+		"statements": len(queries),
+		"rows":       rows,
+		"duration":   time.Since(start).String(),
+	})
+	if err != nil {
+		entry.WithError(err).Warn("Cypher batch failed")
+	} else {
+		entry.Debug("Cypher batch executed")
+	}
+	return err
+}
+
+// transactionLogger adds the transaction id to log entries, so one request can be followed through the writer and
+// Neo4j logs
+func transactionLogger(transactionID string) *log.Entry {
+	return log.WithField(transactionidutils.TransactionIDKey, transactionID)
+}
+
+// rowCount adds up the rows decoded into each query's result
+func rowCount(queries []*neoism.CypherQuery) int {
+	rows := 0
+	for _, q := range queries {
+		if q.Result == nil {
+			continue
+		}
+		if v := reflect.ValueOf(q.Result); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+			rows += v.Elem().Len()
+		}
+	}
+	return rows
+}
+
+// EnableOTLPTracing sends spans to an OpenTelemetry collector's OTLP/HTTP endpoint, e.g. http://localhost:4318.
+// Without it spans are not even recorded. Spans are sent in batches, and dropped if the collector cannot keep up
+// rather than slowing down writes.
+func EnableOTLPTracing(endpoint string, serviceName string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+		otlptracehttp.WithTimeout(10 * time.Second),
+	}
+	switch u.Scheme {
+	case "http":
+		opts = append(opts, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return fmt.Errorf("endpoint %s is not an http or https URL", endpoint)
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxQueueSize(spanExportQueueSize),
+			sdktrace.WithMaxExportBatchSize(maxSpansPerExport),
+			sdktrace.WithBatchTimeout(spanExportInterval),
+		),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	return nil
+}
+
+// FlushTracing sends the spans still waiting to be exported and stops the exporter, as is needed before exiting. It
+// does nothing when tracing is off.
+func FlushTracing(timeout time.Duration) {
+	if tracerProvider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Warnf("Could not flush traces, error=[%s]", err)
+	}
+}
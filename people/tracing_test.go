@@ -0,0 +1,240 @@
+package people
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmcvetta/neoism"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type stubConnection struct {
+	rows int
+}
+
+func (c stubConnection) CypherBatch(queries []*neoism.CypherQuery) error {
+	for _, q := range queries {
+		if result, ok := q.Result.(*[]storedIdentifier); ok {
+			*result = make([]storedIdentifier, c.rows)
+		}
+	}
+	return nil
+}
+
+func (c stubConnection) EnsureConstraints(indexes map[string]string) error { return nil }
+func (c stubConnection) EnsureIndexes(indexes map[string]string) error     { return nil }
+
+// failingConnection fails every batch with the same error
+type failingConnection struct {
+	stubConnection
+	err error
+}
+
+func (c failingConnection) CypherBatch(queries []*neoism.CypherQuery) error { return c.err }
+
+// withRecordedSpans records the spans started while f runs, without exporting them
+func withRecordedSpans(t *testing.T, f func(recorder *tracetest.SpanRecorder)) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	f(recorder)
+}
+
+func spanAttributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestCypherSpansAreChildrenOfTheRequest(t *testing.T) {
+	withRecordedSpans(t, func(recorder *tracetest.SpanRecorder) {
+		s := NewCypherPeopleService(stubConnection{rows: 3})
+
+		handler := traced("GET /test", func(w http.ResponseWriter, r *http.Request) {
+			results := []storedIdentifier{}
+			assert.NoError(t, s.cypherBatchContext(r.Context(), r.Header.Get("X-Request-Id"), "read", []*neoism.CypherQuery{{Result: &results}, {}}))
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Request-Id", "tid_test")
+		req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		handler(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		assert.Len(t, spans, 2)
+		cypher, request := spans[0], spans[1]
+
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", request.SpanContext().TraceID().String())
+		assert.Equal(t, "b7ad6b7169203331", request.Parent().SpanID().String())
+		assert.Equal(t, trace.SpanKindServer, request.SpanKind())
+		assert.Equal(t, int64(http.StatusNotFound), spanAttributes(request)["http.status_code"].AsInt64())
+		assert.Equal(t, codes.Unset, request.Status().Code)
+
+		assert.Equal(t, request.SpanContext().TraceID(), cypher.SpanContext().TraceID())
+		assert.Equal(t, request.SpanContext().SpanID(), cypher.Parent().SpanID())
+		assert.Equal(t, "read", cypher.Name())
+		assert.Equal(t, trace.SpanKindClient, cypher.SpanKind())
+		assert.Equal(t, int64(2), spanAttributes(cypher)["db.statement_count"].AsInt64())
+		assert.Equal(t, int64(3), spanAttributes(cypher)["db.row_count"].AsInt64())
+		assert.Equal(t, "tid_test", spanAttributes(cypher)["ft.transaction_id"].AsString())
+	})
+}
+
+func TestTracedRequestsKeepTheirHeaders(t *testing.T) {
+	withRecordedSpans(t, func(recorder *tracetest.SpanRecorder) {
+		var handled string
+		handler := traced("GET /test", func(w http.ResponseWriter, r *http.Request) {
+			handled = r.Header.Get("X-Request-Id")
+		})
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		handler(httptest.NewRecorder(), req)
+
+		assert.Empty(t, req.Header.Get("X-Request-Id"), "the request the handler was given should not be changed")
+		assert.NotEmpty(t, handled)
+		spans := recorder.Ended()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, handled, spanAttributes(spans[0])["ft.transaction_id"].AsString())
+		}
+	})
+}
+
+func TestConcurrentRequestsWithTheSameTransactionAreTracedSeparately(t *testing.T) {
+	withRecordedSpans(t, func(recorder *tracetest.SpanRecorder) {
+		s := NewCypherPeopleService(stubConnection{})
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		handler := traced("GET /test", func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			assert.NoError(t, s.cypherBatchContext(r.Context(), "tid_replayed", "read", []*neoism.CypherQuery{{}}))
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest("GET", "/test", nil)
+				req.Header.Set("X-Request-Id", "tid_replayed")
+				handler(httptest.NewRecorder(), req)
+			}()
+		}
+		<-started
+		<-started
+		close(release)
+		wg.Wait()
+
+		parents := make(map[trace.SpanID]int)
+		requests := make(map[trace.SpanID]bool)
+		for _, sp := range recorder.Ended() {
+			if sp.SpanKind() == trace.SpanKindServer {
+				requests[sp.SpanContext().SpanID()] = true
+			} else {
+				parents[sp.Parent().SpanID()]++
+			}
+		}
+		assert.Len(t, requests, 2)
+		for parent, children := range parents {
+			assert.True(t, requests[parent])
+			assert.Equal(t, 1, children)
+		}
+	})
+}
+
+func TestFailedBatchesAreErrorSpans(t *testing.T) {
+	withRecordedSpans(t, func(recorder *tracetest.SpanRecorder) {
+		s := NewCypherPeopleService(failingConnection{err: errors.New("boom")})
+		assert.Error(t, s.cypherBatch("", "write", []*neoism.CypherQuery{{}}))
+
+		spans := recorder.Ended()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, codes.Error, spans[0].Status().Code)
+			assert.Equal(t, "boom", spans[0].Status().Description)
+			assert.False(t, spans[0].Parent().IsValid(), "a batch without a request should start its own trace")
+		}
+	})
+}
+
+func TestNoSpansWhenTracingIsOff(t *testing.T) {
+	_, sp := tracer().Start(context.Background(), "read")
+	assert.False(t, sp.IsRecording())
+}
+
+func TestFlushSendsQueuedSpans(t *testing.T) {
+	var received []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path)
+	}))
+	defer collector.Close()
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	assert.NoError(t, EnableOTLPTracing(collector.URL, "people-rw-neo4j"))
+	s := NewCypherPeopleService(stubConnection{})
+	assert.NoError(t, s.cypherBatch("", "read", []*neoism.CypherQuery{{}}))
+	assert.NoError(t, s.cypherBatch("", "write", []*neoism.CypherQuery{{}}))
+
+	FlushTracing(time.Second)
+	tracerProvider = nil
+	assert.Equal(t, []string{"/v1/traces"}, received, "the spans should be sent straight away, not on the next tick")
+}
+
+func TestEnableOTLPTracingNeedsAnHTTPEndpoint(t *testing.T) {
+	assert.Error(t, EnableOTLPTracing("localhost:4318", "people-rw-neo4j"))
+}
+
+type recordingHook struct {
+	sync.Mutex
+	entries []*log.Entry
+}
+
+func (h *recordingHook) Levels() []log.Level { return log.AllLevels }
+
+func (h *recordingHook) Fire(e *log.Entry) error {
+	h.Lock()
+	defer h.Unlock()
+	h.entries = append(h.entries, e)
+	return nil
+}
+
+func TestCypherBatchesAreLoggedAgainstTheTransaction(t *testing.T) {
+	hook := &recordingHook{}
+	log.AddHook(hook)
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	s := NewCypherPeopleService(stubConnection{rows: 2})
+	results := []storedIdentifier{}
+	assert.NoError(t, s.cypherBatch("tid_logged", "read", []*neoism.CypherQuery{{Result: &results}}))
+
+	hook.Lock()
+	defer hook.Unlock()
+	var logged *log.Entry
+	for _, e := range hook.entries {
+		if e.Data["transaction_id"] == "tid_logged" {
+			logged = e
+		}
+	}
+	if assert.NotNil(t, logged) {
+		assert.Equal(t, log.DebugLevel, logged.Level)
+		assert.Equal(t, "read", logged.Data["operation"])
+		assert.Equal(t, 2, logged.Data["rows"])
+	}
+}
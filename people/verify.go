@@ -0,0 +1,156 @@
+package people
+
+import (
+	"github.com/jmcvetta/neoism"
+)
+
+// DefaultRepairBatchSize is how many problems are fixed per transaction by default
+const DefaultRepairBatchSize = 1000
+
+// maxConsistencyExamples is how many of the nodes with a problem are listed in the report
+const maxConsistencyExamples = 10
+
+// consistencyCheck finds one kind of integrity problem, and fixes it. Each statement is given the problem nodes as
+// p. The repair statement is given a limit and must return how many nodes it fixed as count. Problems which need
+// someone to decide what is right have no repair statement, and are only reported.
+type consistencyCheck struct {
+	name        string
+	description string
+	match       string
+	example     string
+	repair      string
+}
+
+var orphanedIdentifiersCheck = consistencyCheck{
+	name:        "orphanedIdentifiers",
+	description: "Identifier nodes which do not identify anything",
+	match:       `MATCH (p:Identifier) WHERE NOT (p)-[:IDENTIFIES]->()`,
+	example:     `p.value`,
+	repair:      `WITH p LIMIT {limit} DETACH DELETE p RETURN count(*) as count`,
+}
+
+var consistencyChecks = []consistencyCheck{
+	orphanedIdentifiersCheck,
+	{
+		name:        "missingUPPIdentifier",
+		description: "Person nodes without their own uuid as a UPPIdentifier",
+		match:       `MATCH (p:Person) WHERE NOT (:` + uppIdentifierLabel + ` {value:p.uuid})-[:IDENTIFIES]->()`,
+		example:     `p.uuid`,
+		// nothing else is identified by the uuid, so an Identifier node with it can only be an orphan
+		repair: `WITH p LIMIT {limit}
+				MERGE (i:Identifier:` + uppIdentifierLabel + ` {value:p.uuid})
+				MERGE (i)-[:IDENTIFIES]->(p)
+				RETURN count(*) as count`,
+	},
+	{
+		name:        "conflictingUPPIdentifier",
+		description: "Person nodes whose own uuid is a UPPIdentifier of another node",
+		match: `MATCH (p:Person) WHERE (:` + uppIdentifierLabel + ` {value:p.uuid})-[:IDENTIFIES]->()
+				AND NOT (:` + uppIdentifierLabel + ` {value:p.uuid})-[:IDENTIFIES]->(p)`,
+		example: `p.uuid`,
+	},
+	{
+		name:        "bareThings",
+		description: "Thing nodes with no other labels and no relationships, left behind by deletes",
+		match:       `MATCH (p:Thing) WHERE size(labels(p)) = 1 AND NOT (p)--()`,
+		example:     `p.uuid`,
+		repair:      `WITH p LIMIT {limit} DELETE p RETURN count(*) as count`,
+	},
+	{
+		name:        "personsWithoutConcept",
+		description: "Person nodes without the Concept label",
+		match:       `MATCH (p:Person) WHERE NOT p:Concept`,
+		example:     `p.uuid`,
+		repair:      `WITH p LIMIT {limit} SET p:Concept RETURN count(*) as count`,
+	},
+}
+
+// consistencyProblem is the result of one check
+type consistencyProblem struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Examples    []string `json:"examples"`
+	Repaired    int      `json:"repaired"`
+}
+
+// Verify runs every consistency check, reporting how many nodes have each problem with a few examples. With repair,
+// problems are fixed batchSize nodes at a time.
+func (s service) Verify(repair bool, batchSize int) ([]consistencyProblem, error) {
+	problems := []consistencyProblem{}
+	for _, check := range consistencyChecks {
+		problem, err := s.verify(check)
+		if err != nil {
+			return nil, err
+		}
+		if repair && problem.Count > 0 && check.repair != "" {
+			if problem.Repaired, err = s.repair(check, batchSize); err != nil {
+				return nil, err
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems, nil
+}
+
+func (s service) verify(check consistencyCheck) (consistencyProblem, error) {
+	counts := []struct {
+		Count int `json:"count"`
+	}{}
+	examples := []struct {
+		Example string `json:"example"`
+	}{}
+
+	queries := []*neoism.CypherQuery{
+		{
+			Statement: check.match + ` RETURN count(p) as count`,
+			Result:    &counts,
+		},
+		{
+			Statement:  check.match + ` RETURN ` + check.example + ` as example ORDER BY example LIMIT {limit}`,
+			Parameters: map[string]interface{}{"limit": maxConsistencyExamples},
+			Result:     &examples,
+		},
+	}
+	if err := s.cypherBatch("", "verify "+check.name, queries); err != nil {
+		return consistencyProblem{}, err
+	}
+
+	problem := consistencyProblem{
+		Name:        check.name,
+		Description: check.description,
+		Examples:    []string{},
+	}
+	if len(counts) > 0 {
+		problem.Count = counts[0].Count
+	}
+	for _, e := range examples {
+		problem.Examples = append(problem.Examples, e.Example)
+	}
+	return problem, nil
+}
+
+// repair runs the check's fix until a batch fixes fewer nodes than the batch size, each batch in its own transaction
+func (s service) repair(check consistencyCheck, batchSize int) (int, error) {
+	repaired := 0
+	for {
+		results := []struct {
+			Count int `json:"count"`
+		}{}
+		query := &neoism.CypherQuery{
+			Statement:  check.match + ` ` + check.repair,
+			Parameters: map[string]interface{}{"limit": batchSize},
+			Result:     &results,
+		}
+		if err := s.cypherBatch("", "repair "+check.name, []*neoism.CypherQuery{query}); err != nil {
+			return repaired, err
+		}
+		if len(results) == 0 {
+			return repaired, nil
+		}
+		repaired += results[0].Count
+		if results[0].Count < batchSize {
+			return repaired, nil
+		}
+	}
+}